@@ -54,7 +54,9 @@ func (p *Program) String() string {
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
-	Value Expression
+	// `let [a, b] = value`のようなdestructuringの場合. Nameはnil
+	Pattern Pattern
+	Value   Expression
 }
 
 func (node *LetStatement) statementNode() {
@@ -65,7 +67,11 @@ func (node *LetStatement) TokenLiteral() string {
 func (node *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(node.TokenLiteral() + " ")
-	out.WriteString(node.Name.String())
+	if node.Pattern != nil {
+		out.WriteString(node.Pattern.String())
+	} else {
+		out.WriteString(node.Name.String())
+	}
 	out.WriteString(" = ")
 	if node.Value != nil {
 		out.WriteString(node.Value.String())
@@ -95,6 +101,28 @@ func (node *ReturnStatement) String() string {
 	return out.String()
 }
 
+// YieldStatement is `yield value;`. only allowed in a function body, which makes the function a generator
+type YieldStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (node *YieldStatement) statementNode() {
+}
+func (node *YieldStatement) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *YieldStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(node.TokenLiteral() + " ")
+
+	if node.Value != nil {
+		out.WriteString(node.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -115,6 +143,7 @@ func (node *ExpressionStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	EndToken   token.Token // }
 }
 
 func (node *BlockStatement) expressionNode() {}
@@ -148,6 +177,7 @@ func (node *Identifier) TokenLiteral() string {
 func (node *Identifier) String() string {
 	return node.Value
 }
+func (node *Identifier) patternNode() {}
 
 type IntegerLiteral struct {
 	Token token.Token
@@ -263,11 +293,81 @@ func (node *IfExpression) String() string {
 	return out.String()
 }
 
+// ForExpression is `for (variable in iterable) { body }`
+type ForExpression struct {
+	Token    token.Token
+	Variable Pattern
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (node *ForExpression) expressionNode() {}
+
+func (node *ForExpression) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *ForExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("for(")
+	out.WriteString(node.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(node.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(node.Body.String())
+	return out.String()
+}
+
+// MatchExpression is `match (subject) { pattern if guard => value, ... }`.
+// the arms are tried in order and the first one which matches gives the value.
+type MatchExpression struct {
+	Token    token.Token // match
+	Subject  Expression
+	Arms     []*MatchArm
+	EndToken token.Token // }
+}
+
+// MatchArm is one `pattern if guard => value` of a match expression
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression // 無い場合はnil
+	Value   Expression
+}
+
+func (node *MatchExpression) expressionNode() {}
+
+func (node *MatchExpression) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range node.Arms {
+		arms = append(arms, arm.String())
+	}
+	return "match(" + node.Subject.String() + ") {" + strings.Join(arms, ", ") + "}"
+}
+
+func (arm *MatchArm) String() string {
+	var out bytes.Buffer
+	out.WriteString(arm.Pattern.String())
+	if arm.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(arm.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(arm.Value.String())
+	return out.String()
+}
+
 // 関数定義の方の式
 type FunctionLiteral struct {
 	Token      token.Token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Parameters []Pattern
+	// Parametersと同じ順の既定値. 既定値の無い引数はnil
+	Defaults []Expression
+	Rest     *Identifier // ...rest. 無い場合はnil
+	Body     *BlockStatement
+	// 本体(入れ子の関数は除く)にyieldを含む. 呼び出すとgeneratorを返す
+	Generator bool
 }
 
 func (node *FunctionLiteral) expressionNode() {}
@@ -279,8 +379,15 @@ func (node *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range node.Parameters {
-		params = append(params, p.String())
+	for i, p := range node.Parameters {
+		if value := node.Default(i); value != nil {
+			params = append(params, p.String()+"="+value.String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if node.Rest != nil {
+		params = append(params, "..."+node.Rest.String())
 	}
 
 	out.WriteString(node.TokenLiteral())
@@ -291,6 +398,28 @@ func (node *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// Default returns the default value of the i-th parameter, or nil if it has none
+func (node *FunctionLiteral) Default(i int) Expression {
+	if i < len(node.Defaults) {
+		return node.Defaults[i]
+	}
+	return nil
+}
+
+// Arity returns the range of the number of arguments the function accepts.
+// max is -1 when the function has a rest parameter.
+func (node *FunctionLiteral) Arity() (min int, max int) {
+	for i := range node.Parameters {
+		if node.Default(i) == nil {
+			min = i + 1
+		}
+	}
+	if node.Rest != nil {
+		return min, -1
+	}
+	return min, len(node.Parameters)
+}
+
 // 関数呼び出しの式
 type CallExpression struct {
 	Token     token.Token
@@ -318,9 +447,42 @@ func (node *CallExpression) String() string {
 	return out.String()
 }
 
+// SpreadExpression is `...value` in arguments, which passes each element as an argument
+type SpreadExpression struct {
+	Token token.Token // ...
+	Value Expression
+}
+
+func (node *SpreadExpression) expressionNode() {}
+
+func (node *SpreadExpression) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *SpreadExpression) String() string {
+	return "..." + node.Value.String()
+}
+
+// NamedArgument is `name: value` in arguments, which binds value to the parameter called name.
+// it follows all positional arguments.
+type NamedArgument struct {
+	Token token.Token // name
+	Name  *Identifier
+	Value Expression
+}
+
+func (node *NamedArgument) expressionNode() {}
+
+func (node *NamedArgument) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *NamedArgument) String() string {
+	return node.Name.String() + ":" + node.Value.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	EndToken token.Token // ]
 }
 
 func (node *ArrayLiteral) expressionNode() {}
@@ -342,6 +504,53 @@ func (node *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// RangeExpression is `start..end`, which includes end, or `start..<end`, which excludes it
+type RangeExpression struct {
+	Token token.Token // .. or ..<
+	Start Expression
+	End   Expression
+}
+
+func (node *RangeExpression) expressionNode() {}
+
+func (node *RangeExpression) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *RangeExpression) String() string {
+	return "(" + node.Start.String() + node.Token.Literal + node.End.String() + ")"
+}
+
+// Inclusive reports whether the range includes End
+func (node *RangeExpression) Inclusive() bool {
+	return node.Token.Type == token.DOTDOT
+}
+
+// SetLiteral is `#{elem, ...}`
+type SetLiteral struct {
+	Token    token.Token // #{
+	Elements []Expression
+	EndToken token.Token // }
+}
+
+func (node *SetLiteral) expressionNode() {}
+
+func (node *SetLiteral) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *SetLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range node.Elements {
+		elements = append(elements, e.String())
+	}
+
+	out.WriteString("#{")
+	out.WriteString(strings.Join(elements, ","))
+	out.WriteString("}")
+	return out.String()
+}
+
 type IndexExpression struct {
 	Token token.Token
 	Left  Expression
@@ -363,9 +572,45 @@ func (node *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is `left[start:end]` or `left[start:end:step]`. omitted parts are nil.
+type SliceExpression struct {
+	Token token.Token // [
+	Left  Expression
+	Start Expression
+	End   Expression
+	Step  Expression
+}
+
+func (node *SliceExpression) expressionNode() {}
+
+func (node *SliceExpression) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(node.Left.String())
+	out.WriteString("[")
+	if node.Start != nil {
+		out.WriteString(node.Start.String())
+	}
+	out.WriteString(":")
+	if node.End != nil {
+		out.WriteString(node.End.String())
+	}
+	if node.Step != nil {
+		out.WriteString(":")
+		out.WriteString(node.Step.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
 type HashLiteral struct {
-	Token token.Token
-	Pairs map[Expression]Expression
+	Token    token.Token
+	Pairs    map[Expression]Expression
+	Keys     []Expression // Pairsのkeyをsourceに現れた順に並べたもの
+	EndToken token.Token  // }
 }
 
 func (node *HashLiteral) expressionNode() {}
@@ -376,8 +621,8 @@ func (node *HashLiteral) TokenLiteral() string {
 func (node *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, val := range node.Pairs {
-		pairs = append(pairs, key.String()+":"+val.String())
+	for _, key := range node.Keys {
+		pairs = append(pairs, key.String()+":"+node.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -385,3 +630,183 @@ func (node *HashLiteral) String() string {
 	out.WriteString("}")
 	return out.String()
 }
+
+/*****************
+* Pattern
+******************/
+
+// Pattern is the left side of a binding, which takes apart the value.
+// an Identifier is the simplest pattern which binds the whole value.
+type Pattern interface {
+	Expression
+	patternNode()
+}
+
+// ArrayPattern is `[a, b = 1, ...rest]`
+type ArrayPattern struct {
+	Token    token.Token // [
+	Elements []Pattern
+	Defaults []Expression // Elementsと同じ順. 既定値の無い要素はnil
+	Rest     *Identifier  // ...rest. 無い場合はnil
+	EndToken token.Token  // ]
+}
+
+func (node *ArrayPattern) expressionNode() {}
+func (node *ArrayPattern) patternNode()    {}
+
+func (node *ArrayPattern) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *ArrayPattern) String() string {
+	elements := []string{}
+	for i, elem := range node.Elements {
+		elements = append(elements, patternString(elem, node.Defaults[i]))
+	}
+	if node.Rest != nil {
+		elements = append(elements, "..."+node.Rest.String())
+	}
+	return "[" + strings.Join(elements, ",") + "]"
+}
+
+// HashPattern is `{name, age: a, role = "guest", ...rest}`. keys are names of string keys.
+type HashPattern struct {
+	Token    token.Token // {
+	Keys     []*Identifier
+	Values   []Pattern    // Keysと同じ順. `{name}`の場合はkeyと同じ名前のIdentifier
+	Defaults []Expression // Keysと同じ順. 既定値の無いkeyはnil
+	Rest     *Identifier  // ...rest. 無い場合はnil
+	EndToken token.Token  // }
+}
+
+func (node *HashPattern) expressionNode() {}
+func (node *HashPattern) patternNode()    {}
+
+func (node *HashPattern) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *HashPattern) String() string {
+	entries := []string{}
+	for i, key := range node.Keys {
+		value := node.Values[i]
+		if ident, ok := value.(*Identifier); ok && ident.Value == key.Value {
+			entries = append(entries, patternString(key, node.Defaults[i]))
+		} else {
+			entries = append(entries, key.String()+":"+patternString(value, node.Defaults[i]))
+		}
+	}
+	if node.Rest != nil {
+		entries = append(entries, "..."+node.Rest.String())
+	}
+	return "{" + strings.Join(entries, ",") + "}"
+}
+
+// WildcardPattern is `_` in a match, which matches anything without binding it
+type WildcardPattern struct {
+	Token token.Token
+}
+
+func (node *WildcardPattern) expressionNode() {}
+func (node *WildcardPattern) patternNode()    {}
+
+func (node *WildcardPattern) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *WildcardPattern) String() string {
+	return "_"
+}
+
+// LiteralPattern matches a value equal to an integer, string or boolean literal.
+// a negative integer is a PrefixExpression.
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (node *LiteralPattern) expressionNode() {}
+func (node *LiteralPattern) patternNode()    {}
+
+func (node *LiteralPattern) TokenLiteral() string {
+	return node.Value.TokenLiteral()
+}
+func (node *LiteralPattern) String() string {
+	return node.Value.String()
+}
+
+// TypePattern is `Integer(n)`, which matches a value of the type and then its inner pattern.
+// `Integer()` checks only the type.
+type TypePattern struct {
+	Token    token.Token // 型の名前
+	Type     string
+	Pattern  Pattern // 無い場合はnil
+	EndToken token.Token
+}
+
+func (node *TypePattern) expressionNode() {}
+func (node *TypePattern) patternNode()    {}
+
+func (node *TypePattern) TokenLiteral() string {
+	return node.Token.Literal
+}
+func (node *TypePattern) String() string {
+	if node.Pattern == nil {
+		return node.Type + "()"
+	}
+	return node.Type + "(" + node.Pattern.String() + ")"
+}
+
+// AlternativePattern is `1 | 2`, which matches when one of the alternatives matches.
+// every alternative binds the same names.
+type AlternativePattern struct {
+	Alternatives []Pattern
+}
+
+func (node *AlternativePattern) expressionNode() {}
+func (node *AlternativePattern) patternNode()    {}
+
+func (node *AlternativePattern) TokenLiteral() string {
+	return node.Alternatives[0].TokenLiteral()
+}
+func (node *AlternativePattern) String() string {
+	alternatives := []string{}
+	for _, alternative := range node.Alternatives {
+		alternatives = append(alternatives, alternative.String())
+	}
+	return strings.Join(alternatives, "|")
+}
+
+func patternString(pattern Pattern, value Expression) string {
+	if value == nil {
+		return pattern.String()
+	}
+	return pattern.String() + "=" + value.String()
+}
+
+// Names returns the identifiers which pattern binds, in source order
+func Names(pattern Pattern) []*Identifier {
+	names := []*Identifier{}
+	switch pattern := pattern.(type) {
+	case *Identifier:
+		names = append(names, pattern)
+	case *ArrayPattern:
+		for _, elem := range pattern.Elements {
+			names = append(names, Names(elem)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest)
+		}
+	case *HashPattern:
+		for _, value := range pattern.Values {
+			names = append(names, Names(value)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest)
+		}
+	case *TypePattern:
+		if pattern.Pattern != nil {
+			names = append(names, Names(pattern.Pattern)...)
+		}
+	case *AlternativePattern:
+		// どの選択肢も同じ名前を束縛する
+		names = append(names, Names(pattern.Alternatives[0])...)
+	}
+	return names
+}
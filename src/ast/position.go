@@ -0,0 +1,92 @@
+package ast
+
+import "choco/src/token"
+
+// Position is a location in source code. both are 1-based.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) Before(other Position) bool {
+	return p.Line < other.Line || p.Line == other.Line && p.Column < other.Column
+}
+
+func positionOf(tok token.Token) Position {
+	return Position{Line: tok.Line, Column: tok.Column}
+}
+
+// Start returns the position of the first token of node.
+// grouping parentheses are not part of ast, so `(a + b)` starts at `a`.
+func Start(node Node) Position {
+	switch node := node.(type) {
+	case *Program:
+		if len(node.Statements) > 0 {
+			return Start(node.Statements[0])
+		}
+		return Position{Line: 1, Column: 1}
+	case *LetStatement:
+		return positionOf(node.Token)
+	case *ReturnStatement:
+		return positionOf(node.Token)
+	case *YieldStatement:
+		return positionOf(node.Token)
+	case *ExpressionStatement:
+		return positionOf(node.Token)
+	case *BlockStatement:
+		return positionOf(node.Token)
+	case *Identifier:
+		return positionOf(node.Token)
+	case *IntegerLiteral:
+		return positionOf(node.Token)
+	case *StringLiteral:
+		// tokenの位置は開始の"を指す
+		return positionOf(node.Token)
+	case *Boolean:
+		return positionOf(node.Token)
+	case *PrefixExpression:
+		return positionOf(node.Token)
+	case *InfixExpression:
+		return Start(node.Left)
+	case *IfExpression:
+		return positionOf(node.Token)
+	case *ForExpression:
+		return positionOf(node.Token)
+	case *MatchExpression:
+		return positionOf(node.Token)
+	case *FunctionLiteral:
+		return positionOf(node.Token)
+	case *CallExpression:
+		return Start(node.Function)
+	case *SpreadExpression:
+		return positionOf(node.Token)
+	case *NamedArgument:
+		return positionOf(node.Token)
+	case *ArrayLiteral:
+		return positionOf(node.Token)
+	case *IndexExpression:
+		return Start(node.Left)
+	case *SliceExpression:
+		return Start(node.Left)
+	case *HashLiteral:
+		return positionOf(node.Token)
+	case *RangeExpression:
+		return Start(node.Start)
+	case *SetLiteral:
+		return positionOf(node.Token)
+	case *ArrayPattern:
+		return positionOf(node.Token)
+	case *HashPattern:
+		return positionOf(node.Token)
+	case *WildcardPattern:
+		return positionOf(node.Token)
+	case *LiteralPattern:
+		return Start(node.Value)
+	case *TypePattern:
+		return positionOf(node.Token)
+	case *AlternativePattern:
+		return Start(node.Alternatives[0])
+	default:
+		return Position{}
+	}
+}
@@ -0,0 +1,138 @@
+package ast
+
+// Inspect traverses node and its children in source order, calling fn for each node.
+// children are skipped when fn returns false.
+func Inspect(node Node, fn func(Node) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, stmt := range node.Statements {
+			Inspect(stmt, fn)
+		}
+	case *LetStatement:
+		if node.Pattern != nil {
+			Inspect(node.Pattern, fn)
+		}
+		inspectExpression(node.Value, fn)
+	case *ReturnStatement:
+		inspectExpression(node.ReturnValue, fn)
+	case *YieldStatement:
+		inspectExpression(node.Value, fn)
+	case *ExpressionStatement:
+		inspectExpression(node.Expression, fn)
+	case *BlockStatement:
+		for _, stmt := range node.Statements {
+			Inspect(stmt, fn)
+		}
+	case *PrefixExpression:
+		inspectExpression(node.Right, fn)
+	case *InfixExpression:
+		inspectExpression(node.Left, fn)
+		inspectExpression(node.Right, fn)
+	case *IfExpression:
+		inspectExpression(node.Condition, fn)
+		if node.Consequence != nil {
+			Inspect(node.Consequence, fn)
+		}
+		if node.Alternative != nil {
+			Inspect(node.Alternative, fn)
+		}
+	case *ForExpression:
+		if node.Variable != nil {
+			Inspect(node.Variable, fn)
+		}
+		inspectExpression(node.Iterable, fn)
+		if node.Body != nil {
+			Inspect(node.Body, fn)
+		}
+	case *MatchExpression:
+		inspectExpression(node.Subject, fn)
+		for _, arm := range node.Arms {
+			inspectExpression(arm.Pattern, fn)
+			inspectExpression(arm.Guard, fn)
+			inspectExpression(arm.Value, fn)
+		}
+	case *FunctionLiteral:
+		for i, param := range node.Parameters {
+			Inspect(param, fn)
+			inspectExpression(node.Default(i), fn)
+		}
+		if node.Rest != nil {
+			Inspect(node.Rest, fn)
+		}
+		if node.Body != nil {
+			Inspect(node.Body, fn)
+		}
+	case *CallExpression:
+		inspectExpression(node.Function, fn)
+		for _, arg := range node.Arguments {
+			inspectExpression(arg, fn)
+		}
+	case *SpreadExpression:
+		inspectExpression(node.Value, fn)
+	case *NamedArgument:
+		if node.Name != nil {
+			Inspect(node.Name, fn)
+		}
+		inspectExpression(node.Value, fn)
+	case *ArrayLiteral:
+		for _, elem := range node.Elements {
+			inspectExpression(elem, fn)
+		}
+	case *RangeExpression:
+		inspectExpression(node.Start, fn)
+		inspectExpression(node.End, fn)
+	case *SetLiteral:
+		for _, elem := range node.Elements {
+			inspectExpression(elem, fn)
+		}
+	case *IndexExpression:
+		inspectExpression(node.Left, fn)
+		inspectExpression(node.Index, fn)
+	case *SliceExpression:
+		inspectExpression(node.Left, fn)
+		inspectExpression(node.Start, fn)
+		inspectExpression(node.End, fn)
+		inspectExpression(node.Step, fn)
+	case *ArrayPattern:
+		for i, elem := range node.Elements {
+			Inspect(elem, fn)
+			inspectExpression(node.Defaults[i], fn)
+		}
+		if node.Rest != nil {
+			Inspect(node.Rest, fn)
+		}
+	case *HashPattern:
+		// keyは値の名前で, 束縛も参照もしない
+		for i, value := range node.Values {
+			Inspect(value, fn)
+			inspectExpression(node.Defaults[i], fn)
+		}
+		if node.Rest != nil {
+			Inspect(node.Rest, fn)
+		}
+	case *LiteralPattern:
+		inspectExpression(node.Value, fn)
+	case *TypePattern:
+		inspectExpression(node.Pattern, fn)
+	case *AlternativePattern:
+		for _, alternative := range node.Alternatives {
+			Inspect(alternative, fn)
+		}
+	case *HashLiteral:
+		for _, key := range node.Keys {
+			inspectExpression(key, fn)
+			inspectExpression(node.Pairs[key], fn)
+		}
+	}
+}
+
+// nilのinterfaceをfnに渡さない
+func inspectExpression(expr Expression, fn func(Node) bool) {
+	if expr != nil {
+		Inspect(expr, fn)
+	}
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// subcommandの定義
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = map[string]*command{}
+
+func register(cmd *command) {
+	commands[cmd.name] = cmd
+}
+
+func init() {
+	register(&command{name: "run", summary: "run a choco program", run: runCommand})
+	register(&command{name: "cover", summary: "show a coverage profile as annotated source", run: coverCommand})
+	register(&command{name: "debug", summary: "start a debug adapter on stdin/stdout", run: debugCommand})
+	register(&command{name: "fmt", summary: "format choco source files", run: fmtCommand})
+	register(&command{name: "lint", summary: "report suspicious code in choco source files", run: lintCommand})
+	register(&command{name: "lsp", summary: "start a language server on stdin/stdout", run: lspCommand})
+	register(&command{name: "test", summary: "run tests in *_test.choco files", run: testCommand})
+	register(&command{name: "help", summary: "show this help", run: helpCommand})
+}
+
+// Main dispatches args(without the binary name) to a subcommand and returns exit status.
+// when the first argument is not a subcommand, it is treated as `choco run`.
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			return cmd.run(args[1:], stdin, stdout, stderr)
+		}
+	}
+	return runCommand(args, stdin, stdout, stderr)
+}
+
+func helpCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	printUsage(stdout)
+	return 0
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintf(out, "usage:\n")
+	fmt.Fprintf(out, "  choco [flags] file.choco [args...]\n")
+	fmt.Fprintf(out, "  choco [flags] -e 'expr' [args...]\n")
+	fmt.Fprintf(out, "  choco <command> [flags] [args...]\n")
+	fmt.Fprintf(out, "\ncommands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-8s %s\n", name, commands[name].summary)
+	}
+}
@@ -0,0 +1,275 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runMain(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	status := Main(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRunCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "choco-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.choco")
+	if err := ioutil.WriteFile(script, []byte("let x = 2; x * len(args);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedStatus int
+		expectedOut    string
+	}{
+		{[]string{"-e", "1 + 2"}, "", 0, "3\n"},
+		{[]string{"-e", "args", "a", "b"}, "", 0, "[a,b]\n"},
+		{[]string{"--quiet", "-e", "1 + 2"}, "", 0, ""},
+		{[]string{"-e", "1 + true"}, "", 1, ""},
+		{[]string{"-e", "let = 1"}, "", 1, ""},
+		{[]string{script, "a", "b", "c"}, "", 0, "6\n"},
+		{[]string{"run", script, "a"}, "", 0, "2\n"},
+		{[]string{"run", "-", "a"}, "len(args) + 10", 0, "11\n"},
+		{[]string{"--dump-ast", "-e", "1 + 2 * 3"}, "", 0, "(1+(2*3))\n"},
+		{[]string{"--dump-tokens", "-e", "x;"}, "", 0, "IDENT\t\"x\"\n;\t\";\"\nEOF\t\"\"\n"},
+		{[]string{"run", filepath.Join(dir, "missing.choco")}, "", 1, ""},
+		{[]string{"-"}, "#!/usr/bin/env choco\nargs", 0, "[]\n"},
+	}
+
+	for _, tt := range tests {
+		status, out, _ := runMain(t, tt.stdin, tt.args...)
+		if status != tt.expectedStatus {
+			t.Errorf("%v: wrong status. want=%d, got=%d", tt.args, tt.expectedStatus, status)
+		}
+		if out != tt.expectedOut {
+			t.Errorf("%v: wrong output. want=%q, got=%q", tt.args, tt.expectedOut, out)
+		}
+	}
+}
+
+func TestRunCommandReportsErrorsToStderr(t *testing.T) {
+	_, _, errOut := runMain(t, "", "-e", "foobar")
+	if errOut != "ERROR: identifier not found: foobar\n" {
+		t.Errorf("wrong stderr. got=%q", errOut)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	status, _, errOut := runMain(t, "", "--strict", "-e", "[1][5]")
+	if status != 1 || errOut != "ERROR: index out of range: 5 with length 1\n" {
+		t.Errorf("wrong result in strict mode. status=%d, stderr=%q", status, errOut)
+	}
+	status, out, _ := runMain(t, "", "-e", "[1][5]")
+	if status != 0 || out != "null\n" {
+		t.Errorf("out-of-range index should be null by default. status=%d, stdout=%q", status, out)
+	}
+}
+
+func TestTraceParser(t *testing.T) {
+	_, _, errOut := runMain(t, "", "--trace-parser", "--quiet", "-e", "1")
+	if !strings.Contains(errOut, "BEGIN parseIntegerLiteral") {
+		t.Errorf("parser trace is not printed. got=%q", errOut)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	_, out, errOut := runMain(t, "", "--trace", "--quiet", "-e", "let f = fn(x) { x * 2 }; puts(f(21))")
+	if out != "42\n" || !strings.Contains(errOut, "-> f(21)\n  1:17 (x*2)\n<- f = 42\n") {
+		t.Errorf("wrong trace. stdout=%q, stderr=%q", out, errOut)
+	}
+}
+
+func TestParserErrorsHaveLineNumbers(t *testing.T) {
+	_, _, errOut := runMain(t, "#!/usr/bin/env choco\n\nlet = 1;", "-")
+	if !strings.HasPrefix(errOut, "\tline 3: ") {
+		t.Errorf("parser error does not point to line 3. got=%q", errOut)
+	}
+}
+
+func TestFmtCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "choco-fmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	messy := filepath.Join(dir, "messy.choco")
+	clean := filepath.Join(dir, "clean.choco")
+	ioutil.WriteFile(messy, []byte("let x=1+2"), 0644)
+	ioutil.WriteFile(clean, []byte("let x = 1 + 2\n"), 0644)
+
+	status, out, _ := runMain(t, "", "fmt", "-l", dir)
+	if status != 0 || out != messy+"\n" {
+		t.Errorf("fmt -l: wrong result. status=%d, out=%q", status, out)
+	}
+
+	status, out, _ = runMain(t, "", "fmt", messy)
+	if status != 0 || out != "let x = 1 + 2\n" {
+		t.Errorf("fmt: wrong result. status=%d, out=%q", status, out)
+	}
+
+	status, out, _ = runMain(t, "", "fmt", "-w", messy)
+	content, _ := ioutil.ReadFile(messy)
+	if status != 0 || out != "" || string(content) != "let x = 1 + 2\n" {
+		t.Errorf("fmt -w: wrong result. status=%d, out=%q, content=%q", status, out, content)
+	}
+
+	status, out, _ = runMain(t, "if(x){1}", "fmt")
+	if status != 0 || out != "if (x) {\n    1\n}\n" {
+		t.Errorf("fmt stdin: wrong result. status=%d, out=%q", status, out)
+	}
+
+	status, _, errOut := runMain(t, "let = 1", "fmt")
+	if status != 1 || !strings.Contains(errOut, "line 1: ") {
+		t.Errorf("fmt parse error: wrong result. status=%d, stderr=%q", status, errOut)
+	}
+}
+
+func TestLintCommand(t *testing.T) {
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedStatus int
+		expectedOut    string
+	}{
+		{[]string{"lint"}, "let x = 1; puts(x)", 0, ""},
+		{[]string{"lint"}, "let x = 1; puts(x, y)", 1, "-:1:20: identifier not found: y (undefined)\n"},
+		{[]string{"lint", "-disable=undefined"}, "let x = 1; puts(x, y)", 0, ""},
+		{[]string{"lint", "-enable=arity"}, "let x = 1; len(y, x)", 1, "-:1:12: wrong number of arguments to len. got=2, want=1 (arity)\n"},
+		{[]string{"lint", "-format=json"}, "puts(y)", 1,
+			"[\n  {\n    \"file\": \"-\",\n    \"rule\": \"undefined\",\n    \"message\": \"identifier not found: y\",\n    \"line\": 1,\n    \"column\": 6\n  }\n]\n"},
+		{[]string{"lint", "-enable=nothing"}, "", 2, ""},
+		{[]string{"lint", "../../examples"}, "", 0, ""},
+	}
+
+	for _, tt := range tests {
+		status, out, _ := runMain(t, tt.stdin, tt.args...)
+		if status != tt.expectedStatus || out != tt.expectedOut {
+			t.Errorf("%v: wrong result. status=%d, out=%q", tt.args, status, out)
+		}
+	}
+}
+
+func TestLspCommand(t *testing.T) {
+	message := func(body string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	stdin := message(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`) + message(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	status, out, _ := runMain(t, stdin, "lsp")
+	if status != 0 || !strings.Contains(out, `"id":1`) {
+		t.Errorf("lsp: wrong result. status=%d, out=%q", status, out)
+	}
+}
+
+func TestDebugCommand(t *testing.T) {
+	message := func(body string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	stdin := message(`{"seq":1,"type":"request","command":"initialize"}`) + message(`{"seq":2,"type":"request","command":"disconnect"}`)
+
+	status, out, _ := runMain(t, stdin, "debug")
+	if status != 0 || !strings.Contains(out, `"event":"initialized"`) || !strings.Contains(out, `"request_seq":2`) {
+		t.Errorf("debug: wrong result. status=%d, out=%q", status, out)
+	}
+}
+
+func TestTestCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "choco-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "math_test.choco"), []byte(`
+test("add", fn() { assertEq(1 + 2, 3) })
+test("sub", fn() { assertEq(3 - 1, 1) })
+`), 0644)
+	// *_test.choco以外は実行しない
+	ioutil.WriteFile(filepath.Join(dir, "main.choco"), []byte(`test("x", fn() { assert(false) })`), 0644)
+
+	status, out, _ := runMain(t, "", "test", dir)
+	if status != 1 || !strings.Contains(out, "--- FAIL: sub") || !strings.Contains(out, "1 failed, 1 passed") {
+		t.Errorf("test: wrong result. status=%d, out=%q", status, out)
+	}
+
+	status, out, _ = runMain(t, "", "test", "-run", "add", "-v", dir)
+	if status != 0 || !strings.Contains(out, "--- PASS: add") || strings.Contains(out, "sub") {
+		t.Errorf("test -run: wrong result. status=%d, out=%q", status, out)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "choco-cover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.choco")
+	profile := filepath.Join(dir, "cover.out")
+	report := filepath.Join(dir, "cover.html")
+	ioutil.WriteFile(script, []byte("if (len(args) > 0) {\n    1\n}\n"), 0644)
+
+	status, _, errOut := runMain(t, "", "run", "--coverprofile="+profile, script)
+	content, _ := ioutil.ReadFile(profile)
+	if status != 0 || !strings.Contains(string(content), script+":2.5 stmt 0\n") {
+		t.Errorf("run --coverprofile: wrong result. status=%d, stderr=%q, profile=%q", status, errOut, content)
+	}
+
+	status, out, _ := runMain(t, "", "cover", profile)
+	if status != 0 || !strings.Contains(out, "50.0% of statements") || !strings.Contains(out, "    2      0 !     1\n") {
+		t.Errorf("cover: wrong result. status=%d, out=%q", status, out)
+	}
+
+	status, _, _ = runMain(t, "", "cover", "-html="+report, profile)
+	content, _ = ioutil.ReadFile(report)
+	if status != 0 || !strings.Contains(string(content), `<span class="uncovered">    1</span>`) {
+		t.Errorf("cover -html: wrong result. status=%d, html=%q", status, content)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "a_test.choco"), []byte(`test("a", fn() { assert(true) })`), 0644)
+	status, out, _ = runMain(t, "", "test", "--cover", dir)
+	if status != 0 || !strings.Contains(out, "\tcoverage: 100.0% of statements, 100.0% of branches\n") {
+		t.Errorf("test --cover: wrong result. status=%d, out=%q", status, out)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "choco-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.choco")
+	profile := filepath.Join(dir, "cpu.pprof")
+	ioutil.WriteFile(script, []byte("let f = fn(x) { x * 2 }\nf(1)\n"), 0644)
+
+	status, _, errOut := runMain(t, "", "run", "--profile="+profile, script)
+	content, _ := ioutil.ReadFile(profile)
+	// gzipのマジックナンバー
+	if status != 0 || len(content) < 2 || content[0] != 0x1f || content[1] != 0x8b {
+		t.Errorf("run --profile: wrong result. status=%d, stderr=%q, profile=%q", status, errOut, content)
+	}
+
+	// 出力先を省略するとscriptを出力先と取り違える
+	status, _, errOut = runMain(t, "", "run", "--profile", script)
+	content, _ = ioutil.ReadFile(script)
+	if status != 2 || !strings.Contains(errOut, "refusing to write profile") || string(content) != "let f = fn(x) { x * 2 }\nf(1)\n" {
+		t.Errorf("run --profile script: wrong result. status=%d, stderr=%q, script=%q", status, errOut, content)
+	}
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"choco/src/coverage"
+	"choco/src/runner"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// choco cover [-html=out.html] profile
+// coverage profileを読み, 行ごとの実行回数をtextかHTMLで出力する
+func coverCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cover", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	html := flags.String("html", "", "write an HTML report to the file instead of text to stdout")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(stderr, "[ERROR] usage: choco cover [-html=out.html] profile\n")
+		return 2
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "[ERROR] %s\n", err)
+		return 1
+	}
+	profiles, err := coverage.ReadProfile(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "[ERROR] %s: %s\n", flags.Arg(0), err)
+		return 1
+	}
+
+	sources := map[string]string{}
+	for _, p := range profiles {
+		src, err := runner.ReadSource(p.File, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "[ERROR] couldn't read %s: %s\n", p.File, err)
+			return 1
+		}
+		sources[p.File] = src
+	}
+
+	if *html == "" {
+		for _, p := range profiles {
+			coverage.WriteText(stdout, p, sources[p.File])
+		}
+		return 0
+	}
+
+	out, err := os.Create(*html)
+	if err != nil {
+		fmt.Fprintf(stderr, "[ERROR] %s\n", err)
+		return 1
+	}
+	defer out.Close()
+	if err := coverage.WriteHTML(out, profiles, sources); err != nil {
+		fmt.Fprintf(stderr, "[ERROR] couldn't write %s: %s\n", *html, err)
+		return 1
+	}
+	return 0
+}
+
+func writeCoverProfile(path string, profiles []*coverage.Profile) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := coverage.WriteProfile(f, profiles); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"choco/src/debugger"
+	"flag"
+	"io"
+)
+
+// choco debug
+// 標準入出力でDebug Adapter Protocolを話す. 実行するファイルはlaunch requestで受け取る
+func debugCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	return debugger.NewAdapter(stdin, stdout, stderr).Serve()
+}
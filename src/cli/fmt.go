@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"choco/src/formatter"
+	"choco/src/runner"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// choco fmt [-w] [-l] [files or directories...]
+// ファイルが指定されない場合は標準入力を整形して標準出力に書く
+func fmtCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write result to the source file instead of stdout")
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintf(stderr, "[ERROR] -w requires file arguments\n")
+			return 2
+		}
+		return formatFile("-", stdin, stdout, stderr, *write, *list)
+	}
+
+	paths, err := collectSources(flags.Args(), ".choco")
+	if err != nil {
+		fmt.Fprintf(stderr, "[ERROR] %s\n", err)
+		return 1
+	}
+
+	status := 0
+	for _, path := range paths {
+		if s := formatFile(path, stdin, stdout, stderr, *write, *list); s != 0 {
+			status = s
+		}
+	}
+	return status
+}
+
+func formatFile(path string, stdin io.Reader, stdout, stderr io.Writer, write, list bool) int {
+	src, err := runner.ReadSource(path, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "[ERROR] couldn't read %s: %s\n", path, err)
+		return 1
+	}
+
+	formatted, err := formatter.Format(src)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s:\n%s\n", path, err)
+		return 1
+	}
+
+	changed := formatted != src
+	if list && changed {
+		fmt.Fprintln(stdout, path)
+	}
+	if write {
+		if changed {
+			if err := ioutil.WriteFile(path, []byte(formatted), 0644); err != nil {
+				fmt.Fprintf(stderr, "[ERROR] couldn't write %s: %s\n", path, err)
+				return 1
+			}
+		}
+		return 0
+	}
+	if !list {
+		io.WriteString(stdout, formatted)
+	}
+	return 0
+}
+
+// 引数のうちdirectoryは配下のsuffixで終わるファイルに展開する
+func collectSources(args []string, suffix string) ([]string, error) {
+	paths := []string{}
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(path, suffix) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"choco/src/linter"
+	"choco/src/runner"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// 1ファイル分の結果. -format=jsonで出力する
+type lintResult struct {
+	File string `json:"file"`
+	linter.Diagnostic
+}
+
+// choco lint [-enable=rules] [-disable=rules] [-format=text|json] [files or directories...]
+// ファイルが指定されない場合は標準入力を検査する. 問題が見つかった場合は1を返す
+func lintCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	enable := flags.String("enable", "", "comma separated rules to run (default: all)")
+	disable := flags.String("disable", "", "comma separated rules to skip")
+	format := flags.String("format", "text", "output format: text or json")
+	listRules := flags.Bool("rules", false, "list available rules and exit")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if *listRules {
+		printRules(stdout)
+		return 0
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "[ERROR] unknown format: %s\n", *format)
+		return 2
+	}
+
+	config, err := lintConfig(*enable, *disable)
+	if err != nil {
+		fmt.Fprintf(stderr, "[ERROR] %s\n", err)
+		return 2
+	}
+
+	paths := []string{"-"}
+	if flags.NArg() > 0 {
+		paths, err = collectSources(flags.Args(), ".choco")
+		if err != nil {
+			fmt.Fprintf(stderr, "[ERROR] %s\n", err)
+			return 1
+		}
+	}
+
+	results := []lintResult{}
+	for _, path := range paths {
+		src, err := runner.ReadSource(path, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "[ERROR] couldn't read %s: %s\n", path, err)
+			return 1
+		}
+		for _, d := range linter.LintSource(src, config) {
+			results = append(results, lintResult{File: path, Diagnostic: d})
+		}
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(results)
+	} else {
+		for _, r := range results {
+			fmt.Fprintf(stdout, "%s:%s\n", r.File, r.Diagnostic)
+		}
+	}
+
+	if len(results) != 0 {
+		return 1
+	}
+	return 0
+}
+
+func lintConfig(enable, disable string) (linter.Config, error) {
+	config := linter.DefaultConfig()
+	if enable == "" && disable == "" {
+		return config, nil
+	}
+
+	config.Rules = map[string]bool{}
+	if enable == "" {
+		for rule := range linter.Rules {
+			config.Rules[rule] = true
+		}
+	}
+	for _, list := range []struct {
+		rules string
+		value bool
+	}{{enable, true}, {disable, false}} {
+		if list.rules == "" {
+			continue
+		}
+		for _, rule := range strings.Split(list.rules, ",") {
+			rule = strings.TrimSpace(rule)
+			if _, ok := linter.Rules[rule]; !ok {
+				return config, fmt.Errorf("unknown rule: %s", rule)
+			}
+			config.Rules[rule] = list.value
+		}
+	}
+	return config, nil
+}
+
+func printRules(out io.Writer) {
+	names := make([]string, 0, len(linter.Rules))
+	for name := range linter.Rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-18s %s\n", name, linter.Rules[name])
+	}
+}
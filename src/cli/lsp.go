@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"choco/src/lsp"
+	"flag"
+	"io"
+)
+
+// choco lsp
+// 標準入出力でLanguage Server Protocolを話す
+func lspCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	return lsp.NewServer(stdin, stdout, stderr).Serve()
+}
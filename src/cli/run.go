@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"choco/src/coverage"
+	"choco/src/profiler"
+	"choco/src/runner"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const defaultScript = "./main.choco"
+
+// choco run [flags] (file.choco | -) [args...]
+// choco run [flags] -e 'expr' [args...]
+func runCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		printUsage(stderr)
+		fmt.Fprintf(stderr, "\nflags:\n")
+		flags.PrintDefaults()
+	}
+
+	var opts runner.Options
+	expr := flags.String("e", "", "evaluate the given expression instead of a file")
+	flags.BoolVar(&opts.Quiet, "quiet", false, "do not print the value of the last statement")
+	flags.BoolVar(&opts.Strict, "strict", false, "make out-of-range indexes and slices errors instead of null")
+	flags.BoolVar(&opts.Trace, "trace", false, "print statements, calls and errors to stderr while evaluating")
+	flags.BoolVar(&opts.TraceParser, "trace-parser", false, "print parser trace to stderr")
+	flags.BoolVar(&opts.DumpTokens, "dump-tokens", false, "print tokens and exit")
+	flags.BoolVar(&opts.DumpAST, "dump-ast", false, "print parsed statements and exit")
+	coverProfile := flags.String("coverprofile", "", "write a coverage profile to the file")
+	profile := flags.String("profile", "", "write a pprof profile of function calls to the file (--profile=path)")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	// `--profile script.choco`でscriptを出力先と取り違えて上書きしないようにする
+	if strings.HasSuffix(*profile, ".choco") {
+		fmt.Fprintf(stderr, "[ERROR] refusing to write profile to %s. pass the output path as --profile=path\n", *profile)
+		return 2
+	}
+
+	rest := flags.Args()
+	var input string
+	if isFlagSet(flags, "e") {
+		input = *expr
+		opts.Args = rest
+		opts.Filename = "-e"
+	} else {
+		filename := defaultScript
+		if len(rest) > 0 {
+			filename = rest[0]
+			opts.Args = rest[1:]
+		} else if _, err := os.Stat(defaultScript); err != nil {
+			fmt.Fprintf(stderr, "[ERROR] filename is not given and couldn't find %s. please place or pass your .choco file\n", defaultScript)
+			return 2
+		}
+
+		src, err := runner.ReadSource(filename, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "[ERROR] couldn't read %s: %s\n", filename, err)
+			return 1
+		}
+		input = src
+		opts.Filename = filename
+	}
+
+	if *coverProfile != "" {
+		opts.Coverage = coverage.NewRecorder()
+	}
+	if *profile != "" {
+		opts.Profiler = profiler.New(opts.Filename)
+	}
+
+	status := runner.Run(input, opts, stdout, stderr)
+	if *coverProfile != "" {
+		if err := writeCoverProfile(*coverProfile, opts.Coverage.Profiles()); err != nil {
+			fmt.Fprintf(stderr, "[ERROR] couldn't write coverage profile: %s\n", err)
+			return 1
+		}
+	}
+	if *profile != "" {
+		if err := writeProfile(*profile, opts.Profiler); err != nil {
+			fmt.Fprintf(stderr, "[ERROR] couldn't write profile: %s\n", err)
+			return 1
+		}
+	}
+	return status
+}
+
+func writeProfile(path string, p *profiler.Profiler) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.WritePprof(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	found := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"choco/src/coverage"
+	"choco/src/evaluator"
+	"choco/src/runner"
+	"choco/src/tester"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// choco test [-run regexp] [-v] [-cover] [-coverprofile=out] [files or directories...]
+// directoryは配下の*_test.chocoを実行する. 指定がない場合はcurrent directory
+func testCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	run := flags.String("run", "", "run only tests whose name matches the regular expression")
+	verbose := flags.Bool("v", false, "print passed tests too")
+	cover := flags.Bool("cover", false, "print statement and branch coverage of each file")
+	coverProfile := flags.String("coverprofile", "", "write a coverage profile to the file. implies -cover")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	opts := tester.Options{Verbose: *verbose}
+	if *cover || *coverProfile != "" {
+		opts.Coverage = coverage.NewRecorder()
+	}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(stderr, "[ERROR] invalid -run: %s\n", err)
+			return 2
+		}
+		opts.Run = re
+	}
+
+	targets := flags.Args()
+	if len(targets) == 0 {
+		targets = []string{"."}
+	}
+	paths, err := collectSources(targets, tester.FILE_SUFFIX)
+	if err != nil {
+		fmt.Fprintf(stderr, "[ERROR] %s\n", err)
+		return 1
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(stdout, "no test files\n")
+		return 0
+	}
+
+	evaluator.SetOutput(stdout)
+	defer evaluator.SetOutput(os.Stdout)
+
+	status := 0
+	for _, path := range paths {
+		src, err := runner.ReadSource(path, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "[ERROR] couldn't read %s: %s\n", path, err)
+			return 1
+		}
+		results, err := tester.RunFile(path, src, opts)
+		if !tester.Report(stdout, path, results, err, opts) {
+			status = 1
+		}
+		if opts.Coverage != nil && err == nil {
+			profiles := opts.Coverage.Profiles()
+			fmt.Fprintf(stdout, "\t%s\n", profiles[len(profiles)-1].Summary())
+		}
+	}
+
+	if *coverProfile != "" {
+		if err := writeCoverProfile(*coverProfile, opts.Coverage.Profiles()); err != nil {
+			fmt.Fprintf(stderr, "[ERROR] couldn't write coverage profile: %s\n", err)
+			return 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,139 @@
+// Package coverage records which statements and branches of choco programs ran.
+package coverage
+
+import (
+	"choco/src/ast"
+	"choco/src/object"
+	"fmt"
+)
+
+// blockの種類
+const (
+	STATEMENT = "stmt"
+	THEN      = "then" // ifの条件が真だった
+	ELSE      = "else" // ifの条件が偽だった. elseが省略されていても数える
+)
+
+// Block is a unit of coverage: a statement or a branch of an if expression
+type Block struct {
+	Line   int
+	Column int
+	Kind   string
+	Count  int
+}
+
+// Profile is the coverage of a source file
+type Profile struct {
+	File   string
+	Blocks []*Block // 位置の順
+}
+
+// Statements returns the number of statements which ran and all statements
+func (p *Profile) Statements() (covered, total int) {
+	return p.count(func(b *Block) bool { return b.Kind == STATEMENT })
+}
+
+// Branches returns the number of if branches which were taken and all branches
+func (p *Profile) Branches() (covered, total int) {
+	return p.count(func(b *Block) bool { return b.Kind != STATEMENT })
+}
+
+func (p *Profile) count(match func(b *Block) bool) (covered, total int) {
+	for _, b := range p.Blocks {
+		if !match(b) {
+			continue
+		}
+		total++
+		if b.Count > 0 {
+			covered++
+		}
+	}
+	return covered, total
+}
+
+// Summary describes the coverage in one line
+func (p *Profile) Summary() string {
+	return fmt.Sprintf("coverage: %s of statements, %s of branches",
+		percent(p.Statements()), percent(p.Branches()))
+}
+
+func percent(covered, total int) string {
+	if total == 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(covered)*100/float64(total))
+}
+
+// Recorder counts the blocks of registered programs while they are evaluated.
+// install it with evaluator.SetHook.
+type Recorder struct {
+	profiles   []*Profile
+	statements map[ast.Statement]*Block
+	branches   map[*ast.IfExpression][2]*Block
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		statements: map[ast.Statement]*Block{},
+		branches:   map[*ast.IfExpression][2]*Block{},
+	}
+}
+
+// Add registers every statement and if branch of program as a block of file
+func (r *Recorder) Add(file string, program *ast.Program) *Profile {
+	profile := &Profile{File: file}
+	block := func(node ast.Node, kind string) *Block {
+		pos := ast.Start(node)
+		b := &Block{Line: pos.Line, Column: pos.Column, Kind: kind}
+		profile.Blocks = append(profile.Blocks, b)
+		return b
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement, *ast.ReturnStatement, *ast.YieldStatement, *ast.ExpressionStatement:
+			r.statements[node.(ast.Statement)] = block(node, STATEMENT)
+		case *ast.IfExpression:
+			r.branches[node] = [2]*Block{block(node, THEN), block(node, ELSE)}
+		}
+		return true
+	})
+
+	r.profiles = append(r.profiles, profile)
+	return profile
+}
+
+// Profiles returns the profiles of registered programs
+func (r *Recorder) Profiles() []*Profile {
+	return r.profiles
+}
+
+// Statement implements evaluator.Hook
+func (r *Recorder) Statement(stmt ast.Statement, env *object.Environment) {
+	if b, ok := r.statements[stmt]; ok {
+		b.Count++
+	}
+}
+
+// Branch implements evaluator.Hook
+func (r *Recorder) Branch(node *ast.IfExpression, consequence bool) {
+	blocks, ok := r.branches[node]
+	if !ok {
+		return
+	}
+	if consequence {
+		blocks[0].Count++
+	} else {
+		blocks[1].Count++
+	}
+}
+
+// Error implements evaluator.Hook
+func (r *Recorder) Error(err *object.Error) {}
+
+// Call implements evaluator.Hook
+func (r *Recorder) Call(call *ast.CallExpression, fn object.Object, args []object.Object, env *object.Environment) {
+}
+
+// Return implements evaluator.Hook
+func (r *Recorder) Return(call *ast.CallExpression, fn object.Object, result object.Object) {}
@@ -0,0 +1,126 @@
+package coverage
+
+import (
+	"bytes"
+	"choco/src/evaluator"
+	"choco/src/lexer"
+	"choco/src/object"
+	"choco/src/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const source = `let abs = fn(n) {
+    if (n < 0) {
+        return -n
+    }
+    n
+}
+abs(3)
+let unused = fn() { 1 }
+`
+
+func record(t *testing.T, file, src string) *Profile {
+	t.Helper()
+	program := parser.New(lexer.New(src)).ParseProgram()
+	recorder := NewRecorder()
+	profile := recorder.Add(file, program)
+
+	evaluator.SetHook(recorder)
+	defer evaluator.SetHook(nil)
+	evaluator.Eval(program, object.NewEnvironment())
+	return profile
+}
+
+func TestRecorder(t *testing.T) {
+	profile := record(t, "abs.choco", source)
+
+	expected := []Block{
+		{1, 1, STATEMENT, 1},
+		{2, 5, STATEMENT, 1},
+		{2, 5, THEN, 0},
+		{2, 5, ELSE, 1},
+		{3, 9, STATEMENT, 0},
+		{5, 5, STATEMENT, 1},
+		{7, 1, STATEMENT, 1},
+		{8, 1, STATEMENT, 1},
+		{8, 21, STATEMENT, 0},
+	}
+	got := []Block{}
+	for _, b := range profile.Blocks {
+		got = append(got, *b)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong blocks.\nwant=%v\ngot =%v", expected, got)
+	}
+
+	if summary := profile.Summary(); summary != "coverage: 71.4% of statements, 50.0% of branches" {
+		t.Errorf("wrong summary. got=%q", summary)
+	}
+}
+
+func TestProfileRoundTrip(t *testing.T) {
+	profiles := []*Profile{record(t, "dir with space/abs.choco", source), record(t, "b.choco", "if (true) { 1 }")}
+
+	var buf bytes.Buffer
+	if err := WriteProfile(&buf, profiles); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "mode: count\ndir with space/abs.choco:1.1 stmt 1\n") {
+		t.Errorf("wrong profile. got=%q", buf.String())
+	}
+
+	read, err := ReadProfile(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, profiles) {
+		t.Errorf("profile changed by round trip. got=%+v", read)
+	}
+
+	for _, input := range []string{"", "mode: count\nfile 1 stmt", "mode: count\nfile:1.1 branch 1"} {
+		if _, err := ReadProfile(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	profile := record(t, "abs.choco", source)
+
+	var buf bytes.Buffer
+	WriteText(&buf, profile, source)
+	expected := `abs.choco: coverage: 71.4% of statements, 50.0% of branches
+    1      1   let abs = fn(n) {
+    2      1 !     if (n < 0) {
+    3      0 !         return -n
+    4              }
+    5      1       n
+    6          }
+    7      1   abs(3)
+    8      1 ! let unused = fn() { 1 }
+`
+	if buf.String() != expected {
+		t.Errorf("wrong text.\nwant=%q\ngot =%q", expected, buf.String())
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	profile := record(t, "abs.choco", source)
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, []*Profile{profile}, map[string]string{"abs.choco": source}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<h2>abs.choco</h2>",
+		`<span class="uncovered">        return -n</span>`,
+		`<span class="partial">    if (n &lt; 0) {</span>`,
+		`<span class="covered">abs(3)</span>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("html doesn't contain %q", want)
+		}
+	}
+}
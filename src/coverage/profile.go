@@ -0,0 +1,81 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const profileHeader = "mode: count"
+
+// WriteProfile writes profiles in the text format read by ReadProfile.
+// each line is `file:line.column kind count`.
+func WriteProfile(out io.Writer, profiles []*Profile) error {
+	if _, err := fmt.Fprintln(out, profileHeader); err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		for _, b := range p.Blocks {
+			if _, err := fmt.Fprintf(out, "%s:%d.%d %s %d\n", p.File, b.Line, b.Column, b.Kind, b.Count); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadProfile reads profiles written by WriteProfile. blocks of the same file are merged into one profile.
+func ReadProfile(in io.Reader) ([]*Profile, error) {
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() || scanner.Text() != profileHeader {
+		return nil, fmt.Errorf("not a coverage profile: missing %q", profileHeader)
+	}
+
+	profiles := []*Profile{}
+	byFile := map[string]*Profile{}
+	for lineNumber := 2; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		file, b, err := parseBlock(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+
+		p, ok := byFile[file]
+		if !ok {
+			p = &Profile{File: file}
+			byFile[file] = p
+			profiles = append(profiles, p)
+		}
+		p.Blocks = append(p.Blocks, b)
+	}
+	return profiles, scanner.Err()
+}
+
+func parseBlock(line string) (string, *Block, error) {
+	// ファイル名は空白を含みうるので後ろから読む
+	fields := strings.Split(line, " ")
+	if len(fields) < 3 {
+		return "", nil, fmt.Errorf("invalid block: %q", line)
+	}
+	location := strings.Join(fields[:len(fields)-2], " ")
+	kind := fields[len(fields)-2]
+	count, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || (kind != STATEMENT && kind != THEN && kind != ELSE) {
+		return "", nil, fmt.Errorf("invalid block: %q", line)
+	}
+
+	colon := strings.LastIndex(location, ":")
+	if colon < 0 {
+		return "", nil, fmt.Errorf("invalid location: %q", location)
+	}
+	var lineNumber, column int
+	if _, err := fmt.Sscanf(location[colon+1:], "%d.%d", &lineNumber, &column); err != nil {
+		return "", nil, fmt.Errorf("invalid location: %q", location)
+	}
+	return location[:colon], &Block{Line: lineNumber, Column: column, Kind: kind, Count: count}, nil
+}
@@ -0,0 +1,116 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// 行の状態
+const (
+	NOT_STATEMENT = ""
+	COVERED       = "covered"
+	PARTIAL       = "partial" // 実行されなかったblockを含む
+	UNCOVERED     = "uncovered"
+)
+
+// Line is a source line annotated with the coverage of blocks starting on it
+type Line struct {
+	Number int
+	Text   string
+	Count  int // 行で始まる文の最大の実行回数
+	Status string
+}
+
+// Annotate splits src into lines annotated with p
+func Annotate(p *Profile, src string) []Line {
+	lines := []Line{}
+	for i, text := range strings.Split(strings.TrimSuffix(src, "\n"), "\n") {
+		lines = append(lines, Line{Number: i + 1, Text: text})
+	}
+
+	covered := map[int]bool{}
+	uncovered := map[int]bool{}
+	for _, b := range p.Blocks {
+		if b.Line < 1 || b.Line > len(lines) {
+			continue
+		}
+		line := &lines[b.Line-1]
+		if b.Kind == STATEMENT && b.Count > line.Count {
+			line.Count = b.Count
+		}
+		if b.Count > 0 {
+			covered[b.Line] = true
+		} else {
+			uncovered[b.Line] = true
+		}
+	}
+
+	for i := range lines {
+		number := lines[i].Number
+		switch {
+		case covered[number] && uncovered[number]:
+			lines[i].Status = PARTIAL
+		case covered[number]:
+			lines[i].Status = COVERED
+		case uncovered[number]:
+			lines[i].Status = UNCOVERED
+		}
+	}
+	return lines
+}
+
+// WriteText writes src with execution counts. lines with blocks which never ran are marked with !.
+func WriteText(out io.Writer, p *Profile, src string) {
+	fmt.Fprintf(out, "%s: %s\n", p.File, p.Summary())
+	for _, line := range Annotate(p, src) {
+		count, mark := "", " "
+		if line.Status != NOT_STATEMENT {
+			count = fmt.Sprintf("%d", line.Count)
+		}
+		if line.Status == PARTIAL || line.Status == UNCOVERED {
+			mark = "!"
+		}
+		fmt.Fprintf(out, "%5d %6s %s %s\n", line.Number, count, mark, line.Text)
+	}
+}
+
+type htmlFile struct {
+	File    string
+	Summary string
+	Lines   []Line
+}
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>choco coverage</title>
+<style>
+body { font-family: sans-serif; }
+pre { background: #fafafa; padding: 8px; }
+.number { color: #999; }
+.count { color: #999; display: inline-block; width: 4em; text-align: right; }
+.covered { background: #d9f2d9; }
+.partial { background: #fff3c4; }
+.uncovered { background: #f8d7d7; }
+</style>
+</head>
+<body>
+{{range .}}<h2>{{.File}}</h2>
+<p>{{.Summary}}</p>
+<pre>{{range .Lines}}<span class="number">{{printf "%5d" .Number}}</span> <span class="count">{{if .Status}}{{.Count}}{{end}}</span> <span class="{{.Status}}">{{.Text}}</span>
+{{end}}</pre>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes a page showing every profile. sources maps file names to their source.
+func WriteHTML(out io.Writer, profiles []*Profile, sources map[string]string) error {
+	files := []htmlFile{}
+	for _, p := range profiles {
+		files = append(files, htmlFile{File: p.File, Summary: p.Summary(), Lines: Annotate(p, sources[p.File])})
+	}
+	return htmlTemplate.Execute(out, files)
+}
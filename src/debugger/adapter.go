@@ -0,0 +1,354 @@
+package debugger
+
+import (
+	"bufio"
+	"choco/src/ast"
+	"choco/src/evaluator"
+	"choco/src/lexer"
+	"choco/src/object"
+	"choco/src/parser"
+	"choco/src/rpc"
+	"choco/src/runner"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// chocoはthreadを1つしか持たない
+const THREAD_ID = 1
+
+// Adapter speaks the Debug Adapter Protocol over a pair of streams and drives a Debugger
+type Adapter struct {
+	in  *bufio.Reader
+	out io.Writer
+	log io.Writer
+
+	mu  sync.Mutex // outとseqを守る
+	seq int
+
+	debugger *Debugger
+	path     string
+	program  *ast.Program
+	launch   LaunchArguments
+	done     chan struct{} // 実行中のprogramが終わると閉じる
+
+	// variablesReferenceの参照先. *object.Environmentかobject.Object
+	// 再開するたびに作り直す
+	references []interface{}
+}
+
+type adapterHandler func(a *Adapter, args json.RawMessage) (interface{}, error)
+
+var adapterHandlers map[string]adapterHandler
+
+func init() {
+	adapterHandlers = map[string]adapterHandler{
+		"initialize":        (*Adapter).initialize,
+		"launch":            (*Adapter).handleLaunch,
+		"setBreakpoints":    (*Adapter).setBreakpoints,
+		"configurationDone": (*Adapter).configurationDone,
+		"threads":           (*Adapter).threads,
+		"stackTrace":        (*Adapter).stackTrace,
+		"scopes":            (*Adapter).scopes,
+		"variables":         (*Adapter).variables,
+		"continue":          resumeWith((*Debugger).Continue),
+		"next":              resumeWith((*Debugger).StepOver),
+		"stepIn":            resumeWith((*Debugger).StepIn),
+		"stepOut":           resumeWith((*Debugger).StepOut),
+		"pause":             (*Adapter).pause,
+		"terminate":         (*Adapter).terminate,
+		"disconnect":        (*Adapter).terminate,
+	}
+}
+
+// NewAdapter reads requests from in and writes responses and events to out. log receives errors.
+func NewAdapter(in io.Reader, out io.Writer, log io.Writer) *Adapter {
+	a := &Adapter{in: bufio.NewReader(in), out: out, log: log, debugger: New()}
+	a.debugger.OnStop = func(reason string) {
+		a.sendEvent("stopped", StoppedEventBody{Reason: reason, ThreadID: THREAD_ID, AllThreadsStopped: true})
+	}
+	return a
+}
+
+// Serve handles requests until disconnect or end of input and returns exit status
+func (a *Adapter) Serve() int {
+	for {
+		body, err := rpc.ReadMessage(a.in)
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(a.log, "[ERROR] %s\n", err)
+			}
+			a.stopProgram()
+			return 1
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			fmt.Fprintf(a.log, "[ERROR] %s\n", err)
+			continue
+		}
+		a.handle(req)
+		if req.Command == "disconnect" {
+			return 0
+		}
+	}
+}
+
+func (a *Adapter) handle(req request) {
+	h, ok := adapterHandlers[req.Command]
+	if !ok {
+		a.respond(req, nil, fmt.Errorf("unsupported command: %s", req.Command))
+		return
+	}
+	body, err := h(a, req.Arguments)
+	a.respond(req, body, err)
+
+	// initializedはinitializeの応答の後に送る
+	if req.Command == "initialize" && err == nil {
+		a.sendEvent("initialized", nil)
+	}
+}
+
+func (a *Adapter) respond(req request, body interface{}, err error) {
+	res := response{Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		res.Message = err.Error()
+	}
+	a.send(func(seq int) interface{} { res.Seq = seq; return res })
+}
+
+func (a *Adapter) sendEvent(name string, body interface{}) {
+	a.send(func(seq int) interface{} {
+		return event{Seq: seq, Type: "event", Event: name, Body: body}
+	})
+}
+
+// programのgoroutineからも呼ばれるのでseqの採番と書き込みをまとめて行う
+func (a *Adapter) send(message func(seq int) interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.seq++
+	if err := rpc.WriteMessage(a.out, message(a.seq)); err != nil {
+		fmt.Fprintf(a.log, "[ERROR] %s\n", err)
+	}
+}
+
+func (a *Adapter) initialize(args json.RawMessage) (interface{}, error) {
+	return Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsConditionalBreakpoints:   true,
+		SupportsTerminateRequest:         true,
+	}, nil
+}
+
+func (a *Adapter) handleLaunch(args json.RawMessage) (interface{}, error) {
+	if err := json.Unmarshal(args, &a.launch); err != nil {
+		return nil, err
+	}
+	if a.launch.Program == "" {
+		return nil, fmt.Errorf("program is not given")
+	}
+
+	src, err := runner.ReadSource(a.launch.Program, strings.NewReader(""))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s: %s", a.launch.Program, err)
+	}
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s:\n%s", a.launch.Program, strings.Join(p.Errors(), "\n"))
+	}
+
+	a.program = program
+	a.path, _ = filepath.Abs(a.launch.Program)
+	return nil, nil
+}
+
+func (a *Adapter) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var p SetBreakpointsArguments
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, err
+	}
+
+	breakpoints := []Breakpoint{}
+	results := []BreakpointResult{}
+	for _, sbp := range p.Breakpoints {
+		breakpoints = append(breakpoints, Breakpoint{Line: sbp.Line, Condition: sbp.Condition})
+		results = append(results, BreakpointResult{Verified: true, Line: sbp.Line})
+	}
+	if err := a.debugger.SetBreakpoints(breakpoints); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"breakpoints": results}, nil
+}
+
+// 設定が終わったのでprogramを実行する
+func (a *Adapter) configurationDone(args json.RawMessage) (interface{}, error) {
+	if a.program == nil {
+		return nil, fmt.Errorf("no program is launched")
+	}
+	if a.done != nil {
+		return nil, fmt.Errorf("program is already running")
+	}
+
+	a.done = make(chan struct{})
+	go a.run()
+	return nil, nil
+}
+
+func (a *Adapter) run() {
+	defer close(a.done)
+
+	env := object.NewEnvironment()
+	env.Set("args", runner.NewArgsArray(a.launch.Args))
+
+	evaluator.SetOutput(&outputWriter{adapter: a, category: "stdout"})
+	defer evaluator.SetOutput(os.Stdout)
+
+	status := 0
+	result, err := a.debugger.Run(a.program, env, a.launch.StopOnEntry)
+	if err != nil {
+		status = 1
+	} else if errObj, ok := result.(*object.Error); ok {
+		a.sendEvent("output", OutputEventBody{Category: "stderr", Output: errObj.Inspect() + "\n"})
+		status = 1
+	}
+
+	a.sendEvent("exited", ExitedEventBody{ExitCode: status})
+	a.sendEvent("terminated", nil)
+}
+
+// 実行中のprogramを止めて終了を待つ
+func (a *Adapter) stopProgram() {
+	if a.done == nil {
+		return
+	}
+	a.debugger.Terminate()
+	<-a.done
+}
+
+func (a *Adapter) threads(args json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{"threads": []Thread{{ID: THREAD_ID, Name: "main"}}}, nil
+}
+
+func (a *Adapter) stackTrace(args json.RawMessage) (interface{}, error) {
+	frames := a.debugger.Frames()
+	source := Source{Name: filepath.Base(a.path), Path: a.path}
+
+	stackFrames := []StackFrame{}
+	for i, frame := range frames {
+		stackFrames = append(stackFrames, StackFrame{
+			// 外側のframeから1, 2, ...と番号を付ける
+			ID:     len(frames) - i,
+			Name:   frame.Name,
+			Source: source,
+			Line:   frame.Line,
+			Column: frame.Column,
+		})
+	}
+	return map[string]interface{}{"stackFrames": stackFrames, "totalFrames": len(stackFrames)}, nil
+}
+
+func (a *Adapter) scopes(args json.RawMessage) (interface{}, error) {
+	var p ScopesArguments
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, err
+	}
+	frames := a.debugger.Frames()
+	if p.FrameID < 1 || p.FrameID > len(frames) {
+		return nil, fmt.Errorf("unknown frame: %d", p.FrameID)
+	}
+
+	scopes := []Scope{}
+	name := "Locals"
+	for env := frames[len(frames)-p.FrameID].Env; env != nil; env = env.Outer() {
+		if env.Outer() == nil {
+			name = "Globals"
+		}
+		scopes = append(scopes, Scope{Name: name, VariablesReference: a.reference(env)})
+		name = "Closure"
+	}
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (a *Adapter) variables(args json.RawMessage) (interface{}, error) {
+	var p VariablesArguments
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, err
+	}
+	if p.VariablesReference < 1 || p.VariablesReference > len(a.references) {
+		return nil, fmt.Errorf("unknown variables reference: %d", p.VariablesReference)
+	}
+
+	variables := []Variable{}
+	switch target := a.references[p.VariablesReference-1].(type) {
+	case *object.Environment:
+		for _, name := range target.Names() {
+			val, _ := target.Get(name)
+			variables = append(variables, a.variable(name, val))
+		}
+	case *object.Array:
+		for i, elem := range target.Elements {
+			variables = append(variables, a.variable(fmt.Sprintf("[%d]", i), elem))
+		}
+	case *object.Hash:
+		for _, pair := range target.Pairs() {
+			variables = append(variables, a.variable(pair.Key.Inspect(), pair.Value))
+		}
+	case *object.Set:
+		for i, elem := range target.Elements() {
+			variables = append(variables, a.variable(fmt.Sprintf("[%d]", i), elem))
+		}
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+func (a *Adapter) variable(name string, val object.Object) Variable {
+	v := Variable{Name: name, Value: val.Inspect(), Type: string(val.Type())}
+	switch val.(type) {
+	case *object.Array, *object.Hash, *object.Set:
+		v.VariablesReference = a.reference(val)
+	}
+	return v
+}
+
+func (a *Adapter) reference(target interface{}) int {
+	a.references = append(a.references, target)
+	return len(a.references)
+}
+
+// programを再開するhandlerを作る
+func resumeWith(step func(d *Debugger) error) adapterHandler {
+	return func(a *Adapter, args json.RawMessage) (interface{}, error) {
+		if err := step(a.debugger); err != nil {
+			return nil, err
+		}
+		a.references = nil
+		return map[string]interface{}{"allThreadsContinued": true}, nil
+	}
+}
+
+func (a *Adapter) pause(args json.RawMessage) (interface{}, error) {
+	a.debugger.Pause()
+	return nil, nil
+}
+
+func (a *Adapter) terminate(args json.RawMessage) (interface{}, error) {
+	a.stopProgram()
+	return nil, nil
+}
+
+// putsの出力をoutput eventにする
+type outputWriter struct {
+	adapter  *Adapter
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.adapter.sendEvent("output", OutputEventBody{Category: w.category, Output: string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,266 @@
+package debugger
+
+import (
+	"bufio"
+	"choco/src/object"
+	"choco/src/rpc"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Debug Adapter Protocolで通信するclient
+type client struct {
+	t        *testing.T
+	in       io.WriteCloser
+	messages chan rawMessage
+	seq      int
+	events   []rawMessage
+	done     chan int
+}
+
+type rawMessage struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+func newClient(t *testing.T) *client {
+	adapterIn, clientOut := io.Pipe()
+	clientIn, adapterOut := io.Pipe()
+	c := &client{t: t, in: clientOut, messages: make(chan rawMessage, 100), done: make(chan int)}
+
+	// adapterは応答の前後にeventを送るので, 書き込みと並行して読む
+	go func() {
+		defer close(c.messages)
+		out := bufio.NewReader(clientIn)
+		for {
+			body, err := rpc.ReadMessage(out)
+			if err != nil {
+				return
+			}
+			var msg rawMessage
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Error(err)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+
+	adapter := NewAdapter(adapterIn, adapterOut, ioutil.Discard)
+	go func() {
+		status := adapter.Serve()
+		adapterOut.Close()
+		c.done <- status
+	}()
+	return c
+}
+
+// requestを送り, 応答のbodyをbodyに読み込む
+func (c *client) request(command string, arguments interface{}, body interface{}) rawMessage {
+	c.t.Helper()
+	c.seq++
+	seq := c.seq
+	req := map[string]interface{}{"seq": seq, "type": "request", "command": command, "arguments": arguments}
+	if err := rpc.WriteMessage(c.in, req); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		msg := c.read()
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg.RequestSeq != seq {
+			c.t.Fatalf("unexpected response. want request_seq=%d, got=%+v", seq, msg)
+		}
+		if body != nil && msg.Success {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatalf("couldn't decode body of %s: %s", command, err)
+			}
+		}
+		return msg
+	}
+}
+
+// 名前がnameのeventが届くまで待つ
+func (c *client) event(name string, body interface{}) {
+	c.t.Helper()
+	for {
+		var msg rawMessage
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.read()
+		}
+		if msg.Type != "event" {
+			c.t.Fatalf("unexpected message while waiting %s: %+v", name, msg)
+		}
+		if msg.Event != name {
+			continue
+		}
+		if body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) read() rawMessage {
+	c.t.Helper()
+	msg, ok := <-c.messages
+	if !ok {
+		c.t.Fatal("adapter closed the connection")
+	}
+	return msg
+}
+
+func TestAdapterSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "choco-debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fact.choco")
+	ioutil.WriteFile(path, []byte(factorial), 0644)
+
+	c := newClient(t)
+	var capabilities Capabilities
+	if res := c.request("initialize", map[string]interface{}{"adapterID": "choco"}, &capabilities); !res.Success || !capabilities.SupportsConditionalBreakpoints {
+		t.Fatalf("initialize failed: %+v", res)
+	}
+	c.event("initialized", nil)
+
+	if res := c.request("launch", LaunchArguments{Program: filepath.Join(dir, "missing.choco")}, nil); res.Success {
+		t.Errorf("launching missing file should fail")
+	}
+	if res := c.request("launch", LaunchArguments{Program: path}, nil); !res.Success {
+		t.Fatalf("launch failed: %s", res.Message)
+	}
+
+	var breakpoints struct{ Breakpoints []BreakpointResult }
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: path},
+		Breakpoints: []SourceBreakpoint{{Line: 3}},
+	}, &breakpoints)
+	if len(breakpoints.Breakpoints) != 1 || !breakpoints.Breakpoints[0].Verified {
+		t.Errorf("wrong breakpoints: %+v", breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+
+	var stopped StoppedEventBody
+	c.event("stopped", &stopped)
+	if stopped.Reason != BREAKPOINT || stopped.ThreadID != THREAD_ID {
+		t.Errorf("wrong stopped event: %+v", stopped)
+	}
+
+	var trace struct{ StackFrames []StackFrame }
+	c.request("stackTrace", StackTraceArguments{ThreadID: THREAD_ID}, &trace)
+	if len(trace.StackFrames) != 4 {
+		t.Fatalf("wrong stack frames: %+v", trace.StackFrames)
+	}
+	top := trace.StackFrames[0]
+	if top.ID != 4 || top.Name != "fact" || top.Line != 3 || top.Source.Path != path {
+		t.Errorf("wrong top frame: %+v", top)
+	}
+
+	var scopes struct{ Scopes []Scope }
+	c.request("scopes", ScopesArguments{FrameID: top.ID}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes: %+v", scopes)
+	}
+
+	var variables struct{ Variables []Variable }
+	c.request("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &variables)
+	if len(variables.Variables) != 1 || variables.Variables[0] != (Variable{Name: "n", Value: "1", Type: "INTEGER"}) {
+		t.Errorf("wrong locals: %+v", variables)
+	}
+
+	c.request("stepOut", nil, nil)
+	c.event("stopped", &stopped)
+	c.request("stackTrace", StackTraceArguments{ThreadID: THREAD_ID}, &trace)
+	if stopped.Reason != STEP || trace.StackFrames[0].Line != 6 {
+		t.Errorf("wrong position after step out: %+v", trace.StackFrames[0])
+	}
+
+	c.request("continue", nil, nil)
+	var output OutputEventBody
+	c.event("output", &output)
+	if output.Category != "stdout" || output.Output != "6\n" {
+		t.Errorf("wrong output: %+v", output)
+	}
+	var exited ExitedEventBody
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("wrong exit code: %d", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	if res := c.request("continue", nil, nil); res.Success {
+		t.Errorf("continue after exit should fail")
+	}
+	c.request("disconnect", nil, nil)
+	if status := <-c.done; status != 0 {
+		t.Errorf("wrong exit status: %d", status)
+	}
+}
+
+func TestAdapterDisconnectWhileStopped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "choco-debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fact.choco")
+	ioutil.WriteFile(path, []byte(factorial), 0644)
+
+	c := newClient(t)
+	c.request("initialize", nil, nil)
+	c.request("launch", LaunchArguments{Program: path, StopOnEntry: true}, nil)
+	c.request("configurationDone", nil, nil)
+
+	var stopped StoppedEventBody
+	c.event("stopped", &stopped)
+	if stopped.Reason != ENTRY {
+		t.Errorf("wrong stopped reason: %s", stopped.Reason)
+	}
+
+	c.request("disconnect", nil, nil)
+	if status := <-c.done; status != 0 {
+		t.Errorf("wrong exit status: %d", status)
+	}
+}
+
+func TestAdapterHashVariables(t *testing.T) {
+	a := NewAdapter(nil, ioutil.Discard, ioutil.Discard)
+	hash := &object.Hash{}
+	for _, key := range []int64{10, 2, 1} {
+		hash.Set(&object.Integer{Value: key}, &object.String{Value: "v"})
+	}
+	ref := a.reference(hash)
+
+	args, _ := json.Marshal(VariablesArguments{VariablesReference: ref})
+	body, err := a.variables(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hashに入れた順に並べる
+	names := []string{}
+	for _, v := range body.(map[string]interface{})["variables"].([]Variable) {
+		names = append(names, v.Name)
+	}
+	if strings.Join(names, " ") != "10 2 1" {
+		t.Errorf("wrong order of hash entries. got=%v", names)
+	}
+}
@@ -0,0 +1,309 @@
+package debugger
+
+import (
+	"choco/src/ast"
+	"choco/src/evaluator"
+	"choco/src/lexer"
+	"choco/src/object"
+	"choco/src/parser"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// 停止した理由. DAPのstopped eventのreasonと同じ値
+const (
+	ENTRY      = "entry"
+	BREAKPOINT = "breakpoint"
+	STEP       = "step"
+	PAUSE      = "pause"
+)
+
+// 実行の再開方法
+type mode int
+
+const (
+	CONTINUE mode = iota
+	STEP_IN
+	STEP_OVER
+	STEP_OUT
+	TERMINATE
+)
+
+// Breakpoint stops the program before a statement on Line.
+// when Condition is not empty, it stops only if the condition is truthy.
+type Breakpoint struct {
+	Line      int
+	Condition string
+	condition ast.Expression
+}
+
+// Frame is a function call on the call stack
+type Frame struct {
+	Name   string
+	Line   int // 実行中の文の位置
+	Column int
+	Env    *object.Environment
+}
+
+// ErrTerminated is returned by Run when the program is terminated by the debugger
+var ErrTerminated = errors.New("terminated by debugger")
+
+// Debugger pauses a program at breakpoints and steps through it.
+// it is driven from another goroutine: Run blocks while the program is stopped,
+// and Continue/Step*/Terminate resume it.
+type Debugger struct {
+	// OnStop is called on the goroutine running the program when it stops
+	OnStop func(reason string)
+
+	mu          sync.Mutex
+	breakpoints map[int]*Breakpoint
+	frames      []*Frame
+	mode        mode
+	depth       int  // step開始時のframe数
+	entry       bool // 最初の文で止まる
+	pause       bool
+	terminate   bool
+	stopped     bool
+	evaluating  bool // 条件式の評価中はhookを無視する
+	resume      chan mode
+}
+
+func New() *Debugger {
+	return &Debugger{breakpoints: map[int]*Breakpoint{}, resume: make(chan mode)}
+}
+
+// SetBreakpoints replaces all breakpoints. it returns an error for a condition which can't be parsed.
+func (d *Debugger) SetBreakpoints(breakpoints []Breakpoint) error {
+	table := map[int]*Breakpoint{}
+	for i := range breakpoints {
+		bp := breakpoints[i]
+		if bp.Condition != "" {
+			p := parser.New(lexer.New(bp.Condition))
+			program := p.ParseProgram()
+			if len(p.Errors()) != 0 || len(program.Statements) != 1 {
+				return fmt.Errorf("invalid condition at line %d: %s", bp.Line, bp.Condition)
+			}
+			stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				return fmt.Errorf("condition at line %d is not an expression: %s", bp.Line, bp.Condition)
+			}
+			bp.condition = stmt.Expression
+		}
+		table[bp.Line] = &bp
+	}
+
+	d.mu.Lock()
+	d.breakpoints = table
+	d.mu.Unlock()
+	return nil
+}
+
+// Run evaluates program in env under the debugger.
+// when stopOnEntry is true, it stops before the first statement.
+func (d *Debugger) Run(program *ast.Program, env *object.Environment, stopOnEntry bool) (result object.Object, err error) {
+	d.mu.Lock()
+	d.frames = []*Frame{{Name: "main", Env: env}}
+	d.mode = CONTINUE
+	d.entry = stopOnEntry
+	d.pause, d.terminate, d.stopped = false, false, false
+	d.mu.Unlock()
+
+	evaluator.SetHook(d)
+	defer evaluator.SetHook(nil)
+	defer func() {
+		// Terminateされた場合はhookからpanicで抜けてくる
+		if r := recover(); r != nil {
+			if r != ErrTerminated {
+				panic(r)
+			}
+			result, err = nil, ErrTerminated
+		}
+	}()
+
+	return evaluator.Eval(program, env), nil
+}
+
+// Frames returns the call stack, innermost first
+func (d *Debugger) Frames() []Frame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	frames := make([]Frame, 0, len(d.frames))
+	for i := len(d.frames) - 1; i >= 0; i-- {
+		frames = append(frames, *d.frames[i])
+	}
+	return frames
+}
+
+// ErrNotStopped is returned when resuming a program which is not stopped
+var ErrNotStopped = errors.New("program is not stopped")
+
+// Continue resumes the stopped program until the next breakpoint
+func (d *Debugger) Continue() error { return d.send(CONTINUE) }
+
+// StepIn resumes the program until the next statement, entering function calls
+func (d *Debugger) StepIn() error { return d.send(STEP_IN) }
+
+// StepOver resumes the program until the next statement in the current function or its callers
+func (d *Debugger) StepOver() error { return d.send(STEP_OVER) }
+
+// StepOut resumes the program until the current function returns
+func (d *Debugger) StepOut() error { return d.send(STEP_OUT) }
+
+// Terminate stops the program before the next statement. Run returns ErrTerminated.
+func (d *Debugger) Terminate() {
+	if d.send(TERMINATE) == ErrNotStopped {
+		d.mu.Lock()
+		d.terminate = true
+		d.mu.Unlock()
+	}
+}
+
+// Stopped reports whether the program is waiting to be resumed
+func (d *Debugger) Stopped() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stopped
+}
+
+func (d *Debugger) send(m mode) error {
+	d.mu.Lock()
+	if !d.stopped {
+		d.mu.Unlock()
+		return ErrNotStopped
+	}
+	d.stopped = false
+	d.mu.Unlock()
+
+	d.resume <- m
+	return nil
+}
+
+// Pause stops the running program before the next statement
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pause = true
+	d.mu.Unlock()
+}
+
+// Statement implements evaluator.Hook
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) {
+	d.mu.Lock()
+	if d.evaluating {
+		d.mu.Unlock()
+		return
+	}
+	if d.terminate {
+		d.mu.Unlock()
+		panic(ErrTerminated)
+	}
+
+	frame := d.frames[len(d.frames)-1]
+	pos := ast.Start(stmt)
+	sameLine := frame.Line == pos.Line
+	frame.Line, frame.Column = pos.Line, pos.Column
+
+	reason := ""
+	switch {
+	case d.entry:
+		reason = ENTRY
+	case d.pause:
+		reason = PAUSE
+	case d.mode == STEP_IN:
+		reason = STEP
+	case d.mode == STEP_OVER && len(d.frames) <= d.depth:
+		reason = STEP
+	case d.mode == STEP_OUT && len(d.frames) < d.depth:
+		reason = STEP
+	}
+	bp := d.breakpoints[pos.Line]
+	d.mu.Unlock()
+
+	if reason == "" && bp != nil && !sameLine && d.conditionHolds(bp, env) {
+		reason = BREAKPOINT
+	}
+	if reason != "" {
+		d.stop(reason)
+	}
+}
+
+// Call implements evaluator.Hook
+func (d *Debugger) Call(call *ast.CallExpression, fn object.Object, args []object.Object, env *object.Environment) {
+	if env == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.evaluating {
+		return
+	}
+
+	name := "fn"
+	if call != nil {
+		name = call.Function.String()
+	}
+	d.frames = append(d.frames, &Frame{Name: name, Env: env})
+}
+
+// Return implements evaluator.Hook
+func (d *Debugger) Return(call *ast.CallExpression, fn object.Object, result object.Object) {
+	if _, ok := fn.(*object.Function); !ok {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.evaluating {
+		return
+	}
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+// Branch implements evaluator.Hook
+func (d *Debugger) Branch(node *ast.IfExpression, consequence bool) {}
+
+// Error implements evaluator.Hook
+func (d *Debugger) Error(err *object.Error) {}
+
+func (d *Debugger) conditionHolds(bp *Breakpoint, env *object.Environment) bool {
+	if bp.condition == nil {
+		return true
+	}
+
+	d.mu.Lock()
+	d.evaluating = true
+	d.mu.Unlock()
+	result := evaluator.Eval(bp.condition, env)
+	d.mu.Lock()
+	d.evaluating = false
+	d.mu.Unlock()
+
+	// 評価に失敗した条件は成り立たないものとする
+	switch result {
+	case nil, evaluator.NULL, evaluator.FALSE:
+		return false
+	}
+	return result.Type() != object.ERROR_OBJ
+}
+
+// 再開されるまでblockする
+func (d *Debugger) stop(reason string) {
+	d.mu.Lock()
+	d.stopped = true
+	d.mu.Unlock()
+
+	if d.OnStop != nil {
+		d.OnStop(reason)
+	}
+	m := <-d.resume
+
+	d.mu.Lock()
+	d.mode = m
+	d.depth = len(d.frames)
+	d.entry, d.pause = false, false
+	d.mu.Unlock()
+
+	if m == TERMINATE {
+		panic(ErrTerminated)
+	}
+}
@@ -0,0 +1,160 @@
+package debugger
+
+import (
+	"choco/src/evaluator"
+	"choco/src/lexer"
+	"choco/src/object"
+	"choco/src/parser"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const factorial = `let fact = fn(n) {
+    if (n <= 1) {
+        return 1
+    }
+    let rest = fact(n - 1)
+    n * rest
+}
+let x = fact(3)
+puts(x)
+`
+
+type session struct {
+	t        *testing.T
+	debugger *Debugger
+	stops    chan string
+	result   chan object.Object
+	err      chan error
+}
+
+func start(t *testing.T, input string, breakpoints []Breakpoint, stopOnEntry bool) *session {
+	t.Helper()
+	program := parser.New(lexer.New(input)).ParseProgram()
+	s := &session{t: t, debugger: New(), stops: make(chan string), result: make(chan object.Object, 1), err: make(chan error, 1)}
+	if err := s.debugger.SetBreakpoints(breakpoints); err != nil {
+		t.Fatal(err)
+	}
+	s.debugger.OnStop = func(reason string) { s.stops <- reason }
+
+	evaluator.SetOutput(ioutil.Discard)
+	go func() {
+		result, err := s.debugger.Run(program, object.NewEnvironment(), stopOnEntry)
+		evaluator.SetOutput(os.Stdout)
+		s.result <- result
+		s.err <- err
+		close(s.stops)
+	}()
+	return s
+}
+
+// 次に止まった理由と位置を確かめる
+func (s *session) expectStop(reason string, line int, depth int) []Frame {
+	s.t.Helper()
+	got, ok := <-s.stops
+	if !ok {
+		s.t.Fatalf("program finished. want stop %s at line %d", reason, line)
+	}
+	frames := s.debugger.Frames()
+	if got != reason || frames[0].Line != line || len(frames) != depth {
+		s.t.Fatalf("wrong stop. want=%s at line %d with %d frames, got=%s at line %d with %d frames",
+			reason, line, depth, got, frames[0].Line, len(frames))
+	}
+	return frames
+}
+
+func (s *session) expectFinish() (object.Object, error) {
+	s.t.Helper()
+	if reason, ok := <-s.stops; ok {
+		s.t.Fatalf("unexpected stop: %s at %+v", reason, s.debugger.Frames()[0])
+	}
+	return <-s.result, <-s.err
+}
+
+func TestBreakpoint(t *testing.T) {
+	s := start(t, factorial, []Breakpoint{{Line: 3}}, false)
+
+	frames := s.expectStop(BREAKPOINT, 3, 4)
+	names := []string{}
+	lines := []int{}
+	for _, f := range frames {
+		names = append(names, f.Name)
+		lines = append(lines, f.Line)
+	}
+	if len(names) != 4 || names[0] != "fact" || names[3] != "main" ||
+		lines[0] != 3 || lines[1] != 5 || lines[2] != 5 || lines[3] != 8 {
+		t.Errorf("wrong call stack. names=%v, lines=%v", names, lines)
+	}
+	testIntegerVariable(t, frames[0].Env, "n", 1)
+	testIntegerVariable(t, frames[1].Env, "n", 2)
+
+	if err := s.debugger.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.expectFinish()
+	if err != nil || result != evaluator.NULL {
+		t.Errorf("wrong result. got=%v, err=%v", result, err)
+	}
+}
+
+func TestConditionalBreakpoint(t *testing.T) {
+	s := start(t, factorial, []Breakpoint{{Line: 6, Condition: "n == 2"}}, false)
+
+	frames := s.expectStop(BREAKPOINT, 6, 3)
+	testIntegerVariable(t, frames[0].Env, "rest", 1)
+
+	s.debugger.Continue()
+	s.expectFinish()
+
+	if err := New().SetBreakpoints([]Breakpoint{{Line: 1, Condition: "n =="}}); err == nil {
+		t.Errorf("invalid condition should be an error")
+	}
+}
+
+func TestStep(t *testing.T) {
+	s := start(t, factorial, nil, true)
+
+	s.expectStop(ENTRY, 1, 1)
+	// 関数の定義は本体に入らない
+	s.debugger.StepOver()
+	s.expectStop(STEP, 8, 1)
+	s.debugger.StepIn()
+	s.expectStop(STEP, 2, 2)
+	s.debugger.StepOver()
+	s.expectStop(STEP, 5, 2)
+	s.debugger.StepIn()
+	s.expectStop(STEP, 2, 3)
+	s.debugger.StepOut()
+	s.expectStop(STEP, 6, 2)
+	s.debugger.StepOut()
+	s.expectStop(STEP, 9, 1)
+
+	if err := s.debugger.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	s.expectFinish()
+
+	if err := s.debugger.Continue(); err != ErrNotStopped {
+		t.Errorf("continue after finish should fail. got=%v", err)
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	s := start(t, factorial, nil, true)
+	s.expectStop(ENTRY, 1, 1)
+
+	s.debugger.Terminate()
+	if _, err := s.expectFinish(); err != ErrTerminated {
+		t.Errorf("wrong error. want=%v, got=%v", ErrTerminated, err)
+	}
+}
+
+func testIntegerVariable(t *testing.T, env *object.Environment, name string, expected int64) {
+	t.Helper()
+	val, ok := env.Get(name)
+	integer, isInteger := val.(*object.Integer)
+	if !ok || !isInteger || integer.Value != expected {
+		t.Errorf("wrong variable %s. want=%d, got=%v", name, expected, val)
+	}
+}
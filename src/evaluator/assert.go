@@ -0,0 +1,177 @@
+package evaluator
+
+import (
+	"choco/src/object"
+	"fmt"
+	"strings"
+)
+
+// TestCollector receives tests registered by the test builtin
+type TestCollector func(name string, fn object.Object)
+
+// nilの間はtestを登録できない
+var testCollector TestCollector
+
+// SetTestCollector makes the test builtin pass tests to c. pass nil to disable it.
+func SetTestCollector(c TestCollector) {
+	testCollector = c
+}
+
+// Call applies fn to args. it is used to run functions from outside of a program.
+func Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(nil, fn, args)
+}
+
+func init() {
+	registerBuiltin("assert", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 2,
+		Params:  []string{"condition", "message"},
+		Fn: func(args ...object.Object) object.Object {
+			if isTruthy(args[0]) {
+				return NULL
+			}
+			return assertionError(args[1:], "")
+		},
+	})
+	registerBuiltin("assertEq", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 3,
+		Params:  []string{"actual", "expected", "message"},
+		Fn: func(args ...object.Object) object.Object {
+			actual, expected := args[0], args[1]
+			if object.Equal(actual, expected) {
+				return NULL
+			}
+			return assertionError(args[2:], inspectDiff(expected, actual))
+		},
+	})
+	registerBuiltin("assertThrows", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 2,
+		Params:  []string{"fn", "message"},
+		Fn: func(args ...object.Object) object.Object {
+			if t := args[0].Type(); t != object.FUNCTION_OBJ && t != object.BUILTIN_OBJ {
+				return newError("first argument to `assertThrows` must be FUNCTION, got %s", t)
+			}
+			var want string
+			if len(args) == 2 {
+				str, ok := args[1].(*object.String)
+				if !ok {
+					return newError("second argument to `assertThrows` must be STRING, got %s", args[1].Type())
+				}
+				want = str.Value
+			}
+
+			result := applyFunction(nil, args[0], nil)
+			errObj, ok := result.(*object.Error)
+			if !ok {
+				return newError("assertion failed: expected an error, got %s", inspect(result))
+			}
+			if !strings.Contains(errObj.Message, want) {
+				return newError("assertion failed: expected an error containing %q, got %q", want, errObj.Message)
+			}
+			return NULL
+		},
+	})
+	registerBuiltin("test", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"name", "fn"},
+		Fn: func(args ...object.Object) object.Object {
+			name, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `test` must be STRING, got %s", args[0].Type())
+			}
+			if args[1].Type() != object.FUNCTION_OBJ {
+				return newError("second argument to `test` must be FUNCTION, got %s", args[1].Type())
+			}
+			if testCollector == nil {
+				return newError("`test` can only be used under `choco test`")
+			}
+			testCollector(name.Value, args[1])
+			return NULL
+		},
+	})
+}
+
+// messageが渡されていればdetailの前に付ける
+func assertionError(message []object.Object, detail string) *object.Error {
+	text := "assertion failed"
+	if len(message) == 1 {
+		text += ": " + inspect(message[0])
+	}
+	if detail != "" {
+		text += "\n" + detail
+	}
+	return newError("%s", text)
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return NULL.Inspect()
+	}
+	return obj.Inspect()
+}
+
+// assertEqの失敗時に表示する差分.
+// 1行の値は最初に異なる位置を^で示し, 複数行の値は行ごとの差分を示す
+func inspectDiff(expected, actual object.Object) string {
+	want, got := expected.Inspect(), actual.Inspect()
+	if expected.Type() != actual.Type() {
+		want = fmt.Sprintf("%s (%s)", want, expected.Type())
+		got = fmt.Sprintf("%s (%s)", got, actual.Type())
+	}
+
+	if !strings.Contains(want, "\n") && !strings.Contains(got, "\n") {
+		column := 0
+		for column < len(want) && column < len(got) && want[column] == got[column] {
+			column++
+		}
+		return fmt.Sprintf("expected: %s\n  actual: %s\n          %s^", want, got, strings.Repeat(" ", column))
+	}
+
+	var out strings.Builder
+	out.WriteString("--- expected\n+++ actual")
+	for _, line := range diffLines(strings.Split(want, "\n"), strings.Split(got, "\n")) {
+		out.WriteString("\n" + line)
+	}
+	return out.String()
+}
+
+// 最長共通部分列による行単位の差分
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := []string{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+	return lines
+}
@@ -3,30 +3,85 @@ package evaluator
 import (
 	"choco/src/object"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
 )
 
+// BuiltinNames returns the sorted names of builtin functions
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupBuiltin returns the builtin function bound to name
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+func init() {
+	for name, builtin := range builtins {
+		registerBuiltin(name, builtin)
+	}
+}
+
+// 他のファイルで定義するbuiltinはinitの順序に依らないようにinitからこれで登録する.
+// applyFunctionを使うbuiltinをbuiltinsの初期値に書くと, applyFunctionからEvalを経てbuiltinsを参照するので初期化が循環する.
+// Paramsは受け付ける引数の数を超えてはいけない
+func registerBuiltin(name string, builtin *object.Builtin) {
+	if builtin.MaxArgs >= 0 && len(builtin.Params) > builtin.MaxArgs {
+		panic(fmt.Sprintf("builtin %s declares %d parameters but takes at most %d arguments", name, len(builtin.Params), builtin.MaxArgs))
+	}
+	builtin.Name = name
+	builtins[name] = builtin
+}
+
+// putsの出力先
+var output io.Writer = os.Stdout
+
+// SetOutput redirects the output of puts to w
+func SetOutput(w io.Writer) {
+	output = w
+}
+
+// Output returns the writer set by SetOutput
+func Output() io.Writer {
+	return output
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"value"},
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-
 			switch arg := args[0].(type) {
 			case *object.String:
 				return &object.Integer{Value: int64(len(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(arg.Len())}
+			case *object.Set:
+				return &object.Integer{Value: int64(arg.Len())}
+			case *object.Range:
+				return &object.Integer{Value: arg.Len()}
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
 		},
 	},
 	"first": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"array"},
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
 			}
@@ -39,10 +94,10 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"array"},
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
 			}
@@ -55,10 +110,10 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"array"},
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
 			}
@@ -74,10 +129,10 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"array", "value"},
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
 			}
@@ -91,10 +146,10 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"pop": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"array"},
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to `pop` must be ARRAY, got %s", args[0].Type())
 			}
@@ -109,12 +164,84 @@ var builtins = map[string]*object.Builtin{
 			return NULL
 		},
 	},
+	"sort": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"array"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `sort` must be ARRAY, got %s", args[0].Type())
+			}
+
+			// 元の位置を並べ替え, 比較は常に配列に現れた順の2つで行う.
+			// sortがどの順で比べてもerrorは配列での順に要素を示す
+			elements := args[0].(*object.Array).Elements
+			order := make([]int, len(elements))
+			for i := range order {
+				order[i] = i
+			}
+			var err *object.Error
+			sort.SliceStable(order, func(i, j int) bool {
+				left, right, swapped := order[i], order[j], false
+				if left > right {
+					left, right, swapped = right, left, true
+				}
+				result, ok := object.Compare(elements[left], elements[right])
+				if !ok && err == nil {
+					err = newError("cannot compare %s and %s", elements[left].Inspect(), elements[right].Inspect())
+				}
+				if swapped {
+					return result > 0
+				}
+				return result < 0
+			})
+			if err != nil {
+				return err
+			}
+			newElements := make([]object.Object, len(order))
+			for i, index := range order {
+				newElements[i] = elements[index]
+			}
+			return &object.Array{Elements: newElements}
+		},
+	},
 	"puts": &object.Builtin{
+		MinArgs: 0,
+		MaxArgs: -1,
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(output, arg.Inspect())
 			}
 			return NULL
 		},
 	},
+	"env": &object.Builtin{
+		MinArgs: 0,
+		MaxArgs: 1,
+		Params:  []string{"name"},
+		Fn: func(args ...object.Object) object.Object {
+			// 引数なしの場合は全環境変数をhashで返す
+			if len(args) == 0 {
+				hash := &object.Hash{}
+				for _, kv := range os.Environ() {
+					idx := strings.Index(kv, "=")
+					if idx < 0 {
+						continue
+					}
+					key := &object.String{Value: kv[:idx]}
+					hash.Set(key, &object.String{Value: kv[idx+1:]})
+				}
+				return hash
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument to `env` must be STRING, got %s", args[0].Type())
+			}
+
+			value, ok := os.LookupEnv(args[0].(*object.String).Value)
+			if !ok {
+				return NULL
+			}
+			return &object.String{Value: value}
+		},
+	},
 }
@@ -22,6 +22,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if node.Pattern != nil {
+			if err := bindPattern(node.Pattern, val, env); err != nil {
+				return err
+			}
+			return nil
+		}
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
 		env.Set(node.Name.Value, val)
 
 	case *ast.BlockStatement:
@@ -34,6 +43,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.YieldStatement:
+		return evalYieldStatement(node, env)
+
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 
@@ -67,31 +79,50 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.ForExpression:
+		return evalForExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		// TODO/NOTE: 関数はastのままfieldに持つ?
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{
+			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Env:        env,
+			Body:       node.Body,
+			Position:   ast.Start(node),
+			Generator:  node.Generator,
+		}
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
 		// NOTE: 先に引数をまとめて評価する
-		args := evalExpressions(node.Arguments, env)
-		if (len(args) == 1) && isError(args[0]) {
-			return args[0]
+		args, named, err := evalArguments(node.Arguments, env)
+		if err != nil {
+			return err
+		}
+		if len(named) > 0 {
+			if args, err = bindNamedArguments(function, args, named); err != nil {
+				return err
+			}
 		}
 
-		return applyFunction(function, args)
+		return applyFunction(node, function, args)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
+	case *ast.SetLiteral:
+		return evalSetLiteral(node, env)
+	case *ast.RangeExpression:
+		return evalRangeExpression(node, env)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -104,6 +135,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 	}
@@ -114,6 +147,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, stmt := range program.Statements {
+		if hook != nil {
+			hook.Statement(stmt, env)
+		}
 		result = Eval(stmt, env)
 
 		switch result := result.(type) {
@@ -130,6 +166,9 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, stmt := range block.Statements {
+		if hook != nil {
+			hook.Statement(stmt, env)
+		}
 		result = Eval(stmt, env)
 
 		if result != nil {
@@ -203,10 +242,14 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 		return evalIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ:
+		return evalArrayInfixExpression(operator, left, right)
+	case left.Type() == object.SET_OBJ && right.Type() == object.SET_OBJ:
+		return evalSetInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
@@ -244,6 +287,52 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
+// 配列は要素を辞書式に比較する
+func evalArrayInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(object.Equal(left, right))
+	case "!=":
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
+	case "<", ">", "<=", ">=":
+		result, ok := object.Compare(left, right)
+		if !ok {
+			return newError("cannot compare %s %s %s", left.Inspect(), operator, right.Inspect())
+		}
+		switch operator {
+		case "<":
+			return nativeBoolToBooleanObject(result < 0)
+		case ">":
+			return nativeBoolToBooleanObject(result > 0)
+		case "<=":
+			return nativeBoolToBooleanObject(result <= 0)
+		default:
+			return nativeBoolToBooleanObject(result >= 0)
+		}
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalSetInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftSet := left.(*object.Set)
+	rightSet := right.(*object.Set)
+	switch operator {
+	case "|":
+		return leftSet.Union(rightSet)
+	case "&":
+		return leftSet.Intersect(rightSet)
+	case "-":
+		return leftSet.Difference(rightSet)
+	case "==":
+		return nativeBoolToBooleanObject(object.Equal(left, right))
+	case "!=":
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -264,6 +353,9 @@ func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Ob
 	if isError(condition) {
 		return condition
 	}
+	if hook != nil {
+		hook.Branch(node, isTruthy(condition))
+	}
 	if isTruthy(condition) {
 		return Eval(node.Consequence, env)
 	} else if node.Alternative != nil {
@@ -287,7 +379,11 @@ func isTruthy(o object.Object) bool {
 }
 
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	err := &object.Error{Message: fmt.Sprintf(format, a...)}
+	if hook != nil {
+		hook.Error(err)
+	}
+	return err
 }
 
 func isError(o object.Object) bool {
@@ -311,25 +407,226 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// name: valueで渡された引数
+type namedArgument struct {
+	name  string
+	value object.Object
+}
+
+// ...valueは要素ごとの引数に展開する
+func evalArguments(exps []ast.Expression, env *object.Environment) ([]object.Object, []namedArgument, *object.Error) {
+	var args []object.Object
+	var named []namedArgument
+
+	for _, expr := range exps {
+		switch expr := expr.(type) {
+		case *ast.NamedArgument:
+			value := Eval(expr.Value, env)
+			if err, ok := value.(*object.Error); ok {
+				return nil, nil, err
+			}
+			named = append(named, namedArgument{name: expr.Name.Value, value: value})
+		case *ast.SpreadExpression:
+			value := Eval(expr.Value, env)
+			if err, ok := value.(*object.Error); ok {
+				return nil, nil, err
+			}
+			iterable, ok := value.(object.Iterable)
+			if !ok {
+				return nil, nil, newError("cannot spread %s", value.Type())
+			}
+			it := iterable.Iterate()
+			for elem, ok := it.Next(); ok; elem, ok = it.Next() {
+				if err, ok := elem.(*object.Error); ok {
+					return nil, nil, err
+				}
+				args = append(args, elem)
+			}
+		default:
+			value := Eval(expr, env)
+			if err, ok := value.(*object.Error); ok {
+				return nil, nil, err
+			}
+			args = append(args, value)
+		}
+	}
+
+	return args, named, nil
+}
+
+// 名前付きの引数を引数の位置に置く. 渡されなかった位置はnilのままにする
+func bindNamedArguments(fn object.Object, args []object.Object, named []namedArgument) ([]object.Object, *object.Error) {
+	var name string
+	var params []string
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		name = fn.Name
+		// patternの引数は名前で渡せない
+		for _, param := range fn.Parameters {
+			if ident, ok := param.(*ast.Identifier); ok {
+				params = append(params, ident.Value)
+			} else {
+				params = append(params, "")
+			}
+		}
 	case *object.Builtin:
-		return fn.Fn(args...)
+		name = fn.Name
+		params = fn.Params
+	default:
+		return nil, newError("not a function: %s", fn.Type())
+	}
+	if name == "" {
+		name = "function"
+	}
+
+	bound := append([]object.Object{}, args...)
+	for _, arg := range named {
+		idx := -1
+		for i, param := range params {
+			if param == arg.name {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, newError("%s has no parameter named %s", name, arg.name)
+		}
+		for len(bound) <= idx {
+			bound = append(bound, nil)
+		}
+		if bound[idx] != nil {
+			return nil, newError("argument %s to %s is given more than once", arg.name, name)
+		}
+		bound[idx] = arg.value
+	}
+
+	// builtinは既定値を持たないので間を空けられない
+	if _, ok := fn.(*object.Builtin); ok {
+		for i, arg := range bound {
+			if arg == nil {
+				return nil, newError("missing argument %s to %s", params[i], name)
+			}
+		}
+	}
+	return bound, nil
+}
+
+// callはhookに渡す呼び出し元の式
+func applyFunction(call *ast.CallExpression, fn object.Object, args []object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Function:
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		if hook == nil {
+			return evalFunctionBody(fn, extendedEnv)
+		}
+		hook.Call(call, fn, args, extendedEnv)
+		result := evalFunctionBody(fn, extendedEnv)
+		hook.Return(call, fn, result)
+		return result
+	case *object.Builtin:
+		// builtinは宣言した数の引数で呼ばれる
+		if err := checkArity(fn.Name, len(args), fn.MinArgs, fn.MaxArgs); err != nil {
+			return err
+		}
+		if hook == nil {
+			return fn.Fn(args...)
+		}
+		hook.Call(call, fn, args, nil)
+		result := fn.Fn(args...)
+		hook.Return(call, fn, result)
+		return result
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// generatorの場合は本体を実行せずに返す
+func evalFunctionBody(fn *object.Function, env *object.Environment) object.Object {
+	if fn.Generator {
+		return newGenerator(fn, env)
+	}
+	return unwrapReturnValue(Eval(fn.Body, env))
+}
+
+// builtinから関数を呼ぶ. 受け取らない余分な引数は渡さない
+func applyCallback(fn object.Object, args []object.Object) object.Object {
+	if f, ok := fn.(*object.Function); ok {
+		if _, max := f.Arity(); max >= 0 && len(args) > max {
+			args = args[:max]
+		}
+	}
+	return applyFunction(nil, fn, args)
+}
+
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	min, max := fn.Arity()
+	if err := checkArity(fn.Name, len(args), min, max); err != nil {
+		return nil, err
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) && args[paramIdx] != nil {
+			if err := bindPattern(param, args[paramIdx], env); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// 名前付きの引数で飛ばされた位置
+		if paramIdx >= len(fn.Defaults) || fn.Defaults[paramIdx] == nil {
+			if fn.Name == "" {
+				return nil, newError("missing argument %s", param.String())
+			}
+			return nil, newError("missing argument %s to %s", param.String(), fn.Name)
+		}
+		// 既定値は呼び出しごとに評価する. 前の引数を参照できる
+		value := Eval(fn.Defaults[paramIdx], env)
+		if err, ok := value.(*object.Error); ok {
+			return nil, err
+		}
+		// hookに渡す引数も埋める
+		if paramIdx < len(args) {
+			args[paramIdx] = value
+		}
+		if err := bindPattern(param, value, env); err != nil {
+			return nil, err
+		}
+	}
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+	return env, nil
+}
+
+// 引数の数が合わない場合のerror. 名前の無い関数は名前を示さない
+func checkArity(name string, got int, min int, max int) *object.Error {
+	if got >= min && (max < 0 || got <= max) {
+		return nil
+	}
+	if name == "" {
+		return newError("wrong number of arguments. got=%d, want=%s", got, DescribeArity(min, max))
+	}
+	return newError("wrong number of arguments to %s. got=%d, want=%s", name, got, DescribeArity(min, max))
+}
+
+// DescribeArity describes how many arguments a function takes, e.g. "2", "1 to 2" or "1 or more".
+// a negative max means no upper limit.
+func DescribeArity(min int, max int) string {
+	switch {
+	case min == max:
+		return fmt.Sprintf("%d", min)
+	case max < 0:
+		return fmt.Sprintf("%d or more", min)
+	default:
+		return fmt.Sprintf("%d to %d", min, max)
 	}
-	return env
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -355,6 +652,8 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalhashIndexExpression(left, index)
 	default:
@@ -364,27 +663,57 @@ func evalIndexExpression(left, index object.Object) object.Object {
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	// out of range
-	if idx < 0 || idx > max {
+	idx, err := normalizeIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if err != nil {
+		return err
+	}
+	if idx < 0 {
 		return NULL
 	}
 	return arrayObject.Elements[idx]
 }
 
+// 文字列はruneを単位に添字を数える
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx, err := normalizeIndex(index.(*object.Integer).Value, len(runes))
+	if err != nil {
+		return err
+	}
+	if idx < 0 {
+		return NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+// 負の添字を末尾からの位置にする. 範囲外の場合は-1を返し, strict modeではerrorにする
+func normalizeIndex(idx int64, length int) (int, *object.Error) {
+	original := idx
+	if idx < 0 {
+		idx += int64(length)
+	}
+	if idx < 0 || idx >= int64(length) {
+		if strict {
+			return -1, newError("index out of range: %d with length %d", original, length)
+		}
+		return -1, nil
+	}
+	return int(idx), nil
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := &object.Hash{}
 
-	for keyNode, valueNode := range node.Pairs {
+	// 書かれた順に評価して挿入する
+	for _, keyNode := range node.Keys {
+		valueNode := node.Pairs[keyNode]
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+		if err := checkHashable(key); err != nil {
+			return err
 		}
 
 		value := Eval(valueNode, env)
@@ -392,24 +721,44 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(key, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
+}
+
+func evalSetLiteral(node *ast.SetLiteral, env *object.Environment) object.Object {
+	set := &object.Set{}
+	for _, elemNode := range node.Elements {
+		elem := Eval(elemNode, env)
+		if isError(elem) {
+			return elem
+		}
+		if !set.Add(elem) {
+			return newError("unusable as set element: %s", elem.Type())
+		}
+	}
+	return set
 }
 
 func evalhashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
-	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+	if err := checkHashable(index); err != nil {
+		return err
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(index)
 	if !ok {
 		return NULL
 	}
 	return pair.Value
 }
+
+// 配列やhashは中身が全てhash可能な場合だけキーにできる
+func checkHashable(key object.Object) *object.Error {
+	if _, ok := object.HashKeyOf(key); !ok {
+		return newError("unusable as hash key: %s", key.Type())
+	}
+	return nil
+}
@@ -4,7 +4,10 @@ import (
 	"choco/src/lexer"
 	"choco/src/object"
 	"choco/src/parser"
+	"os"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -316,6 +319,165 @@ ourFunction(20) + first + second;`
 	testIntegerObject(t, testEval(input), 70)
 }
 
+func TestParametersAndArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let add = fn(a, b) { a + b }; add(1)`, "ERROR: wrong number of arguments to add. got=1, want=2"},
+		{`let add = fn(a, b) { a + b }; add(1, 2, 3)`, "ERROR: wrong number of arguments to add. got=3, want=2"},
+		{`fn(a) { a }(1, 2)`, "ERROR: wrong number of arguments. got=2, want=1"},
+		{`let f = fn(a, b = 10) { a + b }; [f(1), f(1, 2)]`, "[11,3]"},
+		{`let f = fn(a, b = a * 2) { b }; f(3)`, "6"},
+		{`let f = fn(a = 1, b = a + 1) { [a, b] }; f()`, "[1,2]"},
+		{`let f = fn(a, b = 1) { a }; f()`, "ERROR: wrong number of arguments to f. got=0, want=1 to 2"},
+		// 既定値は使う場合だけ評価する
+		{`let f = fn(a, b = x) { a }; f(1, 2)`, "1"},
+		{`let f = fn(a, b = x) { a }; f(1)`, "ERROR: identifier not found: x"},
+		{`let f = fn(first, ...rest) { [first, rest] }; [f(1), f(1, 2, 3)]`, "[[1,[]],[1,[2,3]]]"},
+		{`let f = fn(...xs) { len(xs) }; f()`, "0"},
+		{`let f = fn(a, ...r) { a }; f()`, "ERROR: wrong number of arguments to f. got=0, want=1 or more"},
+		{`let add = fn(a, b) { a + b }; add(...[1, 2])`, "3"},
+		{`let f = fn(...xs) { xs }; f(0, ...1..3, ...#{4}, 5)`, "[0,1,2,3,4,5]"},
+		{`len(...["abc"])`, "3"},
+		{`let add = fn(a, b) { a + b }; add(...[1, 2, 3])`, "ERROR: wrong number of arguments to add. got=3, want=2"},
+		{`let f = fn(a) { a }; f(...1)`, "ERROR: cannot spread INTEGER"},
+		{`let f = fn(a) { a }; f(...fn() { yield 1; x }())`, "ERROR: identifier not found: x"},
+		{`fn(a, b = 1, ...c) { a }`, "fn(a, b = 1, ...c) {\na\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	render := `let render = fn(u, compact = false, depth = 1) { [u, compact, depth] };`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{render + `render("u", compact: true, depth: 3)`, "[u,true,3]"},
+		{render + `render("u", depth: 3)`, "[u,false,3]"},
+		{render + `render(depth: 3, u: "x")`, "[x,false,3]"},
+		{render + `render(...["u", true], depth: 2)`, "[u,true,2]"},
+		{render + `render("u", verbose: true)`, "ERROR: render has no parameter named verbose"},
+		{render + `render("u", depth: 1, depth: 2)`, "ERROR: argument depth to render is given more than once"},
+		{render + `render("u", u: "v")`, "ERROR: argument u to render is given more than once"},
+		{render + `render(depth: 2)`, "ERROR: missing argument u to render"},
+		{render + `render("u", depth: x)`, "ERROR: identifier not found: x"},
+		{`fn(a) { a }(b: 1)`, "ERROR: function has no parameter named b"},
+		{`let f = fn(a, ...r) { [a, r] }; f(a: 1)`, "[1,[]]"},
+		{`5(a: 1)`, "ERROR: not a function: INTEGER"},
+		// builtinはParamsに宣言した名前で受け取る
+		{`next(take(0..3, n: 0), default: "none")`, "none"},
+		{`assertEq(1, expected: 1)`, "null"},
+		{`next(default: 1)`, "ERROR: missing argument iterator to next"},
+		{`len(x: 1)`, "ERROR: len has no parameter named x"},
+		{`push(array: [1], value: 2)`, "[1,2]"},
+		{`push([1], value: 2)`, "[1,2]"},
+		{`len(value: "abc")`, "3"},
+		{`has(key: "a", hash: {"a": 1})`, "true"},
+		{`set({}, value: 2, key: "b")`, "{b: 2}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let [a, b, ...rest] = [1, 2, 3, 4]; [a, b, rest]`, "[1,2,[3,4]]"},
+		{`let [a, ...rest] = [1]; rest`, "[]"},
+		{`let [a, b = 10] = [1]; [a, b]`, "[1,10]"},
+		{`let [a, b = a * 2] = [3]; b`, "6"},
+		{`let [a, [b, c]] = [1, [2, 3]]; a + b + c`, "6"},
+		{`let {name, age} = {"name": "u", "age": 3}; [name, age]`, "[u,3]"},
+		{`let {name: n, role = "guest"} = {"name": "u"}; [n, role]`, "[u,guest]"},
+		{`let {a, ...others} = {"a": 1, "b": 2, "c": 3}; others`, "{b: 2,c: 3}"},
+		{`let {user: {name}, tags: [first, ..._]} = {"user": {"name": "u"}, "tags": ["x", "y"]}; [name, first]`, "[u,x]"},
+		{`let [a, b] = [1]`, "ERROR: not enough elements to destructure with [a,b]: got 1"},
+		{`let [a] = [1, 2]`, "ERROR: too many elements to destructure with [a]: got 2"},
+		{`let [a] = 1`, "ERROR: cannot destructure INTEGER with [a]"},
+		{`let {name} = [1]`, "ERROR: cannot destructure ARRAY with {name}"},
+		{`let {name} = {}`, "ERROR: missing key name to destructure with {name}"},
+		{`let {user: {name}} = {"user": 1}`, "ERROR: cannot destructure INTEGER with {name}"},
+		{`let [a = x] = []`, "ERROR: identifier not found: x"},
+		// 引数
+		{`let f = fn([a, b], {name}) { [a, b, name] }; f([1, 2], {"name": "u"})`, "[1,2,u]"},
+		{`let f = fn({depth = 1} = {}) { depth }; [f(), f({"depth": 3})]`, "[1,3]"},
+		{`let f = fn([a, b]) { a }; f(1)`, "ERROR: cannot destructure INTEGER with [a,b]"},
+		{`let f = fn([a]) { a }; f(x: 1)`, "ERROR: f has no parameter named x"},
+		{`fn([a, b = 1], {name: n}) { a }`, "fn([a,b=1], {name:n}) {\na\n"},
+		// forの変数
+		{`let sum = 0; for ([k, v] in entries({"a": 1, "b": 2})) { let sum = sum + v }; sum`, "3"},
+		{`let names = []; for ({name} in [{"name": "a"}, {"name": "b"}]) { let names = push(names, name) }; names`, "[a,b]"},
+		{`for ([a, b] in [[1]]) { a }`, "ERROR: not enough elements to destructure with [a,b]: got 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match (2) { 1 => "one", 2 => "two", _ => "many" }`, "two"},
+		{`match (5) { 1 => "one", 2 => "two", _ => "many" }`, "many"},
+		{`match ("b") { "a" | "b" => 1, _ => 2 }`, "1"},
+		{`match (-1) { -1 => "minus", _ => "other" }`, "minus"},
+		{`match (true) { false => 0, true => 1 }`, "1"},
+		{`match (3) { n => n * 2 }`, "6"},
+		{`match (3) { n if n > 5 => "big", n if n > 0 => "small", _ => "negative" }`, "small"},
+		{`match ([1, 2, 3]) { [] => 0, [x] => x, [x, ...rest] => rest }`, "[2,3]"},
+		{`match ([1, [2, 3]]) { [1, [a, b]] => a + b, _ => 0 }`, "5"},
+		{`match ([2, 3]) { [1, x] => x, [_, x] => x * 10 }`, "30"},
+		{`match ([1]) { [a, b = 5] => a + b }`, "6"},
+		{`match ({"role": "admin", "name": "u"}) { {role: "guest"} => "guest", {role: "admin", name} => name }`, "u"},
+		{`match ({"name": "u"}) { {role} => role, _ => "none" }`, "none"},
+		{`match (1) { String(s) => s, Integer(n) => n + 1 }`, "2"},
+		{`match ("a") { Integer(n) | String(n) => n }`, "a"},
+		{`match (len) { Function() => "fn", _ => "value" }`, "fn"},
+		{`match ([[1], "a"]) { [Array([x]), String()] => x }`, "1"},
+		{`match (1..3) { Iterator() => "it", _ => "other" }`, "other"},
+		{`match (5) { 1 => 1 }`, "ERROR: no match for 5"},
+		{`match ([1, 2]) { [a] => a }`, "ERROR: no match for [1,2]"},
+		{`match (1) { Number(n) => n }`, "ERROR: unknown type Number in pattern Number(n)"},
+		{`match (1) { n if x => n }`, "ERROR: identifier not found: x"},
+		{`match (x) { _ => 1 }`, "ERROR: identifier not found: x"},
+		// 腕の束縛は腕の外に残らない
+		{`let x = 0; match ([1, 2]) { [x, 3] => x, [_, y] => y }; x`, "0"},
+		{`let n = 5; match (9) { Integer(n) => n }; n`, "5"},
+		{`match ([1, 2]) { [_, y] => y }; y`, "ERROR: identifier not found: y"},
+		{`let g = fn(xs) { for (x in xs) { match (x) { Integer(n) => if (true) { yield n * 2 }, _ => 0 } } }; let it = g([1, "a", 2]); [next(it), next(it), next(it)]`, "[2,4,null]"},
+		{`let f = fn(xs) { match (xs) { [] => 0, [x, ...rest] => x + f(rest) } }; f([1, 2, 3])`, "6"},
+		{`let f = fn(n) { match (n) { 0 => if (true) { return "zero" }, _ => "other" }; "after" }; [f(0), f(1)]`, "[zero,after]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = fn(x) {
@@ -365,7 +527,10 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len("one", "two")`, "wrong number of arguments to len. got=2, want=1"},
+		{`push([])`, "wrong number of arguments to push. got=1, want=2"},
+		{`assertEq(1)`, "wrong number of arguments to assertEq. got=1, want=2 to 3"},
+		{`merge()`, "wrong number of arguments to merge. got=0, want=1 or more"},
 		{`len([1, 2, 3])`, 3},
 		{`len([])`, 0},
 		{`puts("hello", "world!")`, nil},
@@ -482,6 +647,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -523,12 +696,13 @@ func TestHashLiterals(t *testing.T) {
 		FALSE.HashKey():                            6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, pair := range result.Pairs() {
+		key, _ := object.HashKeyOf(pair.Key)
+		expectedValue, ok := expected[key]
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
@@ -583,6 +757,487 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] != [1, 2]`, false},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, [2, "a"]] == [1, [2, "a"]]`, true},
+		{`[1] == ["1"]`, false},
+		{`[] == []`, true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{"a": {"b": [1]}} != {"a": {"b": [1]}}`, false},
+		{`[1] == 1`, false},
+		{`let f = fn() {}; [f] == [f]`, true},
+		{`[fn() {}] == [fn() {}]`, false},
+		{`[1, 2] < [1, 3]`, true},
+		{`[1, 2] < [1, 2, 0]`, true},
+		{`[2] > [1, 9]`, true},
+		{`[[1, "b"]] >= [[1, "a"]]`, true},
+		{`[1, 2] <= [1, 2]`, true},
+		{`[1] < ["a"]`, "cannot compare [1] < [a]"},
+		{`[1] + [2]`, "unknown operator: ARRAY + ARRAY"},
+		{`{} < {}`, "unknown operator: HASH < HASH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestSortBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sort([3, 1, 2])`, "[1,2,3]"},
+		{`sort(["b", "c", "a"])`, "[a,b,c]"},
+		{`sort([[2, "a"], [1, "b"], [1, "a"]])`, "[[1,a],[1,b],[2,a]]"},
+		{`let a = [2, 1]; sort(a); a`, "[2,1]"},
+		{`sort([])`, "[]"},
+		{`sort([1, "a"])`, "ERROR: cannot compare 1 and a"},
+		{`sort([3, 2, true])`, "ERROR: cannot compare 3 and true"},
+		{`sort([[1], [1, "a"], [1, 2]])`, "ERROR: cannot compare [1,a] and [1,2]"},
+		{`sort(1)`, "ERROR: argument to `sort` must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHashInspectKeepsInsertionOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, `{b: 1,a: 2,3: 3,true: 4}`},
+		{`{"z": 1, "y": {"x": 2, "w": 3}}`, `{z: 1,y: {x: 2,w: 3}}`},
+		// 重複したキーは最初の位置に後の値が入る
+		{`{"a": 1, "b": 2, "a": 3}`, `{a: 3,b: 2}`},
+	}
+
+	for _, tt := range tests {
+		// 毎回同じ順序になることを確かめる
+		for i := 0; i < 10; i++ {
+			evaluated := testEval(tt.input)
+			if evaluated.Inspect() != tt.expected {
+				t.Fatalf("wrong order. want=%s, got=%s", tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestCompositeHashKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let grid = {[0, 1]: "a", [1, 0]: "b"}; [grid[[0, 1]], grid[[1, 0]], grid[[1, 1]]]`, "[a,b,null]"},
+		{`{[1, [2, "x"]]: 1}[[1, [2, "x"]]]`, "1"},
+		{`{{"a": 1, "b": 2}: "ab"}[{"b": 2, "a": 1}]`, "ab"},
+		{`{[1]: 1, ["1"]: 2}`, "{[1]: 1,[1]: 2}"},
+		{`{[1, 2]: 1, [1, 2]: 2}`, "{[1,2]: 2}"},
+		{`has({[]: 1}, [])`, "true"},
+		{`{[fn(){}]: 1}`, "ERROR: unusable as hash key: ARRAY"},
+		{`{"a": 1}[[fn(){}]]`, "ERROR: unusable as hash key: ARRAY"},
+		{`len(toSet([1, 2, 1, [3], [3]]))`, "3"},
+		{`toSet([3, 1, 3, [2], "a"])`, "#{3,1,[2],a}"},
+		{`toSet([1, 2]) == toSet([2, 1])`, "true"},
+		{`{toSet([1, 2]): "x"}[toSet([2, 1])]`, "x"},
+		{`toSet([fn(){}])`, "ERROR: unusable as set element: FUNCTION_OBJ"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`#{}`, "#{}"},
+		{`#{3, 1, 2, 1}`, "#{3,1,2}"},
+		{`#{[1, 2], [1, 2], {"a": 1}}`, "#{[1,2],{a: 1}}"},
+		{`#{fn(){}}`, "ERROR: unusable as set element: FUNCTION_OBJ"},
+		{`len(#{1, 2, 2})`, "2"},
+		{`has(#{1, 2}, 2)`, "true"},
+		{`has(#{1, 2}, "2")`, "false"},
+		{`has(1, 2)`, "ERROR: argument to `has` must be HASH or SET, got INTEGER"},
+		{`let s = #{1}; let t = add(s, 2); [s, t]`, "[#{1},#{1,2}]"},
+		{`add(#{1}, 1)`, "#{1}"},
+		{`let s = #{1, 2}; let t = remove(s, 1); [s, t]`, "[#{1,2},#{2}]"},
+		{`remove(#{1}, fn(){})`, "ERROR: unusable as set element: FUNCTION_OBJ"},
+		{`union(#{1, 2}, #{3, 2})`, "#{1,2,3}"},
+		{`intersect(#{1, 2, 3}, #{3, 2, 4})`, "#{2,3}"},
+		{`difference(#{1, 2, 3}, #{2})`, "#{1,3}"},
+		{`isSubset(#{1, 2}, #{2, 3, 1})`, "true"},
+		{`isSubset(#{1, 4}, #{2, 3, 1})`, "false"},
+		{`union(#{1}, [1])`, "ERROR: arguments to `union` must be SET, got ARRAY"},
+		{`#{1, 2} | #{3, 2}`, "#{1,2,3}"},
+		{`#{1, 2, 3} & #{3, 2, 4}`, "#{2,3}"},
+		{`#{1, 2, 3} - #{2}`, "#{1,3}"},
+		{`#{1} | #{2} & #{2, 3}`, "#{1,2}"},
+		{`#{1, 2} == #{2, 1}`, "true"},
+		{`#{1, 2} != #{1}`, "true"},
+		{`#{1} + #{2}`, "ERROR: unknown operator: SET + SET"},
+		{`#{1} | [2]`, "ERROR: type mismatch: SET | ARRAY"},
+		{`toArray(#{"b", "a"})`, "[b,a]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSlicing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3, 4][1:3]`, "[2,3]"},
+		{`[1, 2, 3, 4][:2]`, "[1,2]"},
+		{`[1, 2, 3, 4][2:]`, "[3,4]"},
+		{`[1, 2, 3, 4][:]`, "[1,2,3,4]"},
+		{`[1, 2, 3, 4][-2:]`, "[3,4]"},
+		{`[1, 2, 3, 4][:-1]`, "[1,2,3]"},
+		{`[1, 2, 3, 4][::2]`, "[1,3]"},
+		{`[1, 2, 3, 4][1::2]`, "[2,4]"},
+		{`[1, 2, 3, 4][::-1]`, "[4,3,2,1]"},
+		{`[1, 2, 3, 4][2::-1]`, "[3,2,1]"},
+		{`[1, 2, 3, 4][:0:-2]`, "[4,2]"},
+		{`[1, 2, 3, 4][1:100]`, "[2,3,4]"},
+		{`[1, 2, 3, 4][-100:1]`, "[1]"},
+		{`[1, 2, 3, 4][3:1]`, "[]"},
+		{`[][:]`, "[]"},
+		{`let n = 1; [1, 2, 3][n:n + 1]`, "[2]"},
+		{`[1, 2][::0]`, "ERROR: slice step cannot be zero"},
+		// stepが大きくても溢れない
+		{`[1, 2, 3][1::9223372036854775807]`, "[2]"},
+		{`"abc"[2::9223372036854775807]`, "c"},
+		{`[1, 2, 3][::-9223372036854775807 - 1]`, "[3]"},
+		{`[1, 2, 3][-9223372036854775807:9223372036854775807:9223372036854775807]`, "[1]"},
+		{`"abc"[:0:-9223372036854775807]`, "c"},
+		{`[1, 2]["a":]`, "ERROR: slice index must be INTEGER, got STRING"},
+		{`{"a": 1}[1:]`, "ERROR: slice operator not supported: HASH"},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[-3:]`, "llo"},
+		{`"hello"[::-1]`, "olleh"},
+		{`"こんにちは"[2:]`, "にちは"},
+		{`"こんにちは"[1]`, "ん"},
+		{`"こんにちは"[-1]`, "は"},
+		{`"abc"[3]`, "null"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStrictIndexing(t *testing.T) {
+	SetStrict(true)
+	defer SetStrict(false)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3][-1]`, "3"},
+		{`[1, 2, 3][3]`, "ERROR: index out of range: 3 with length 3"},
+		{`[1, 2, 3][-4]`, "ERROR: index out of range: -4 with length 3"},
+		{`"abc"[5]`, "ERROR: index out of range: 5 with length 3"},
+		{`[1, 2, 3][1:3]`, "[2,3]"},
+		{`[1, 2, 3][::-1]`, "[3,2,1]"},
+		{`[1, 2, 3][1:4]`, "ERROR: slice bounds out of range: 4 with length 3"},
+		{`[1, 2, 3][-5:]`, "ERROR: slice bounds out of range: -5 with length 3"},
+		{`{"a": 1}["b"]`, "null"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`0..3`, "0..3"},
+		{`toArray(0..3)`, "[0,1,2,3]"},
+		{`toArray(0..<3)`, "[0,1,2]"},
+		{`let n = 2; toArray(n - 1..n * 2)`, "[1,2,3,4]"},
+		{`toArray(3..1)`, "[]"},
+		{`toArray(1..<1)`, "[]"},
+		{`len(0..<10)`, "10"},
+		{`len(5..1)`, "0"},
+		// int64の端でも溢れない
+		{`len(0..9223372036854775807)`, "9223372036854775807"},
+		{`len((-9223372036854775807 - 1)..9223372036854775807)`, "9223372036854775807"},
+		{`len((-9223372036854775807 - 1)..<0)`, "9223372036854775807"},
+		{`toArray(take(0..9223372036854775807, 3))`, "[0,1,2]"},
+		{`toArray(9223372036854775805..9223372036854775807)`, "[9223372036854775805,9223372036854775806,9223372036854775807]"},
+		{`toArray(9223372036854775806..<9223372036854775807)`, "[9223372036854775806]"},
+		{`0..<3 == 0..2`, "true"},
+		{`1.."a"`, "ERROR: range bounds must be INTEGER, got INTEGER..STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIterators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`toArray([1, 2])`, "[1,2]"},
+		{`toArray("héllo")`, "[h,é,l,l,o]"},
+		{`toArray({"b": 1, "a": 2})`, "[b,a]"},
+		{`toArray(#{2, 1})`, "[2,1]"},
+		{`toArray(1)`, "ERROR: argument to `toArray` must be iterable, got INTEGER"},
+		// 巨大なrangeも必要な分しか読まない
+		{`toArray(take(0..1000000000000, 3))`, "[0,1,2]"},
+		{`toArray(take([1, 2], 5))`, "[1,2]"},
+		{`toArray(drop(0..<5, 2))`, "[2,3,4]"},
+		{`toArray(drop([1], 3))`, "[]"},
+		{`toArray(take(drop(0..1000000000000, 10), 2))`, "[10,11]"},
+		{`toArray(takeWhile(0..1000000000000, fn(x) { x < 3 }))`, "[0,1,2]"},
+		{`toArray(takeWhile(0..10, fn(x) { x + true }))`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`toArray(enumerate("ab"))`, "[[0,a],[1,b]]"},
+		{`toArray(chain([1], 2..3, #{4}))`, "[1,2,3,4]"},
+		{`toArray(chain())`, "[]"},
+		{`chain([1], 2)`, "ERROR: argument to `chain` must be iterable, got INTEGER"},
+		{`take(0..3, "a")`, "ERROR: second argument to `take` must be INTEGER, got STRING"},
+		{`take(0..3, 1)`, "iterator"},
+		// iteratorは一度しか読めない
+		{`let it = take(0..10, 3); toArray(it); toArray(it)`, "[]"},
+		{`let it = enumerate(0..10); toArray(take(it, 2)); toArray(take(it, 2))`, "[[2,2],[3,3]]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	nat := `let nat = fn() { for (i in 0..1000000000000) { yield i } };`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let g = fn() { yield 1; yield 2 }; let it = g(); [next(it), next(it), next(it)]`, "[1,2,null]"},
+		{`let g = fn() { yield 1 }; let it = g(); next(it); next(it, 0)`, "0"},
+		{`let g = fn() { yield 1 }; g()`, "generator g"},
+		{`fn() { yield 1 }()`, "generator"},
+		{`toArray(fn(n) { yield n; yield n * 2 }(3))`, "[3,6]"},
+		{nat + `toArray(take(nat(), 3))`, "[0,1,2]"},
+		{nat + `let it = nat(); next(it); next(it); next(it)`, "2"},
+		// returnで終わる. 値は捨てる
+		{`toArray(fn() { yield 1; return 5; yield 2 }())`, "[1]"},
+		// 入れ子の関数のyieldは外側をgeneratorにしない
+		{`let f = fn() { let g = fn() { yield 1 }; 5 }; f()`, "5"},
+		// 最初のnextまで本体を実行しない
+		{`let it = fn() { yield 1 + true }(); 1`, "1"},
+		// generatorの中のerrorはnextの結果になり, その後は尽きる
+		{`toArray(fn() { yield 1; yield 1 + true }())`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`let it = fn() { yield 1; x }(); next(it); next(it)`, "ERROR: identifier not found: x"},
+		{`let it = fn() { yield x }(); [1, next(it)]`, "ERROR: identifier not found: x"},
+		{`next([1])`, "ERROR: argument to `next` must be an iterator, got ARRAY"},
+		{`next(take(0..3, 1))`, "0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestGeneratorReentry(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let g = fn() { yield next(it) }; let it = g(); next(it)`, "ERROR: generator already running"},
+		{`let g = fn() { for (x in it) { yield x } }; let it = g(); next(it)`, "ERROR: generator already running"},
+		// 終わった後は普通に呼べる
+		{`let g = fn() { yield 1 }; let it = g(); [next(it), next(it), next(it, 0)]`, "[1,null,0]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestForExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let sum = 0; for (x in [1, 2, 3]) { let sum = sum + x }; sum`, "6"},
+		{`let s = ""; for (c in "héllo") { let s = c + s }; s`, "olléh"},
+		{`let ks = []; for (k in {"a": 1, "b": 2}) { let ks = push(ks, k) }; ks`, "[a,b]"},
+		{`let last = 0; for (i in 1..<4) { let last = i }; last`, "3"},
+		{`for (x in []) { x }`, "null"},
+		{`let g = fn() { yield 1; yield 2 }; let sum = 0; for (x in g()) { let sum = sum + x }; sum`, "3"},
+		{`let evens = fn(xs) { for (x in xs) { if (x / 2 * 2 == x) { yield x } } }; toArray(evens(0..6))`, "[0,2,4,6]"},
+		{`let first = fn(xs) { for (x in xs) { if (x > 1) { return x } }; 0 }; first([1, 2, 3])`, "2"},
+		{`for (x in 1) { x }`, "ERROR: cannot iterate over INTEGER"},
+		{`for (x in [1]) { x + true }`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`for (x in fn() { yield 1; y }()) { x }`, "ERROR: identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// 途中で終わったgeneratorのgoroutineが残らない
+func TestGeneratorCleanup(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// for-inをreturnで抜けると閉じる
+	input := `
+let nat = fn() { for (i in 0..1000000000000) { yield i } };
+let first = fn(xs) { for (x in xs) { if (x > 1) { return x } } };
+let it = nat();
+first(it)
+`
+	testIntegerObject(t, testEval(input), 2)
+	waitGoroutines(t, before)
+
+	// 捨てられたgeneratorはGCで閉じる
+	input = `
+let nat = fn() { for (i in 0..1000000000000) { yield i } };
+[toArray(take(nat(), 3)), next(nat())]
+`
+	if evaluated := testEval(input); evaluated.Inspect() != "[[0,1,2],0]" {
+		t.Fatalf("wrong result. got=%s", evaluated.Inspect())
+	}
+	waitGoroutines(t, before)
+}
+
+func waitGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines are left. want=%d, got=%d", want, runtime.NumGoroutine())
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Inspectの結果
+	}{
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`keys({"b": 1, "a": 2})`, "[b,a]"},
+		{`values({"b": 1, "a": 2})`, "[1,2]"},
+		{`entries({"b": 1, 2: true})`, "[[b,1],[2,true]]"},
+		{`fromEntries([["b", 1], [2, true]])`, "{b: 1,2: true}"},
+		{`fromEntries(entries({"a": 1}))`, "{a: 1}"},
+		{`fromEntries([[1]])`, "ERROR: entry 0 of `fromEntries` must be [key, value], got [1]"},
+		{`fromEntries([[fn(){}, 1]])`, "ERROR: unusable as hash key: FUNCTION_OBJ"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({"a": 1}, fn(){})`, "ERROR: unusable as hash key: FUNCTION_OBJ"},
+		{`let h = {"a": 1}; let g = set(h, "b", 2); [h, g]`, "[{a: 1},{a: 1,b: 2}]"},
+		{`set({"a": 1, "b": 2}, "a", 3)`, "{a: 3,b: 2}"},
+		{`let h = {"a": 1, "b": 2}; let g = delete(h, "a"); [h, g]`, "[{a: 1,b: 2},{b: 2}]"},
+		{`delete({"a": 1}, "b")`, "{a: 1}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4}, {"d": 5})`, "{a: 1,b: 3,c: 4,d: 5}"},
+		{`merge({"a": {"x": 1}}, {"a": {"y": 2}})`, "{a: {y: 2}}"},
+		{`deepMerge({"a": {"x": 1, "y": 1}, "b": 1}, {"a": {"y": 2, "z": 3}})`, "{a: {x: 1,y: 2,z: 3},b: 1}"},
+		{`deepMerge({"a": {"x": 1}}, {"a": 1})`, "{a: 1}"},
+		{`let h = {"a": {"x": 1}}; deepMerge(h, {"a": {"y": 2}}); h`, "{a: {x: 1}}"},
+		{`merge({}, 1)`, "ERROR: arguments to `merge` must be HASH, got INTEGER"},
+		{`mapValues({"a": 1, "b": 2}, fn(v) { v * 10 })`, "{a: 10,b: 20}"},
+		{`mapValues({"a": 1}, fn(v, k) { k })`, "{a: a}"},
+		{`mapValues({"a": 1}, fn(v) { v + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`filterEntries({"a": 1, "b": 2, "c": 3}, fn(k, v) { v > 1 })`, "{b: 2,c: 3}"},
+		{`filterEntries({"a": 1, "b": 2}, fn(k) { k == "a" })`, "{a: 1}"},
+		{`keys([])`, "ERROR: argument to `keys` must be HASH, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result of %s. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEnvBuiltin(t *testing.T) {
+	os.Setenv("CHOCO_TEST_ENV", "choco")
+	defer os.Unsetenv("CHOCO_TEST_ENV")
+
+	evaluated := testEval(`env("CHOCO_TEST_ENV")`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "choco" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+
+	testNullObject(t, testEval(`env("CHOCO_TEST_ENV_UNDEFINED")`))
+	testIntegerObject(t, testEval(`len(env()["CHOCO_TEST_ENV"])`), 5)
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
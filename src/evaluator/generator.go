@@ -0,0 +1,167 @@
+package evaluator
+
+import (
+	"choco/src/ast"
+	"choco/src/object"
+	"runtime"
+)
+
+// generatorの本体を止めるためにyieldから投げるpanic
+type generatorClosed struct{}
+
+// generatorの本体を実行するgoroutineから呼び出し側に渡すもの
+type generatorResult struct {
+	value object.Object
+	done  bool        // 本体が終わった. valueがerrorの場合はそれも渡す
+	panic interface{} // 本体の中で起きたpanic. 呼び出し側で投げ直す
+}
+
+// newGenerator returns a generator which runs the body of fn in its own goroutine.
+// the body starts on the first next and stops at each yield until the next one.
+// the goroutine exits when the generator is closed, even while it is waiting at yield,
+// and an abandoned generator is closed when it is garbage collected.
+// a generator bound to a variable which its own body can see, such as a global `let`,
+// stays reachable from the goroutine and is not collected until it is closed or exhausted.
+func newGenerator(fn *object.Function, env *object.Environment) *object.Generator {
+	resume := make(chan struct{})
+	results := make(chan generatorResult)
+	closed := make(chan struct{})
+
+	env.SetYield(func(value object.Object) bool {
+		select {
+		case results <- generatorResult{value: value}:
+		case <-closed:
+			return false
+		}
+		select {
+		case <-resume:
+			return true
+		case <-closed:
+			return false
+		}
+	})
+
+	// NOTE: goroutineからgeneratorを参照するとGCで回収されなくなる
+	run := func() {
+		result := generatorResult{done: true}
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(generatorClosed); ok {
+					return
+				}
+				result.panic = r
+			}
+			select {
+			case results <- result:
+			case <-closed:
+			}
+		}()
+		if value := unwrapReturnValue(Eval(fn.Body, env)); isError(value) {
+			result.value = value
+		}
+	}
+
+	started, finished := false, false
+	next := func() (object.Object, bool) {
+		if finished {
+			return nil, false
+		}
+		if started {
+			resume <- struct{}{}
+		} else {
+			started = true
+			go run()
+		}
+
+		result := <-results
+		if result.panic != nil {
+			finished = true
+			panic(result.panic)
+		}
+		if result.done {
+			finished = true
+			if result.value != nil {
+				return result.value, true
+			}
+			return nil, false
+		}
+		return result.value, true
+	}
+
+	gen := object.NewGenerator(fn.Name, next, func() { close(closed) })
+	runtime.SetFinalizer(gen, func(gen *object.Generator) { gen.Close() })
+	return gen
+}
+
+func evalYieldStatement(node *ast.YieldStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+	yield, ok := env.Yield()
+	if !ok {
+		return newError("yield outside generator")
+	}
+	if !yield(val) {
+		panic(generatorClosed{})
+	}
+	return nil
+}
+
+// 途中で抜ける場合はgeneratorなどを閉じる
+func evalForExpression(node *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	source, ok := iterable.(object.Iterable)
+	if !ok {
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+	it := source.Iterate()
+	if closer, ok := it.(object.Closer); ok {
+		defer closer.Close()
+	}
+
+	for value, ok := it.Next(); ok; value, ok = it.Next() {
+		if isError(value) {
+			return value
+		}
+		if err := bindPattern(node.Variable, value, env); err != nil {
+			return err
+		}
+
+		result := Eval(node.Body, env)
+		if result != nil {
+			resultType := result.Type()
+			if resultType == object.RETURN_VALUE_OBJ || resultType == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+	return NULL
+}
+
+func init() {
+	registerBuiltin("next", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 2,
+		Params:  []string{"iterator", "default"},
+		Fn: func(args ...object.Object) object.Object {
+			it, ok := args[0].(object.Iterator)
+			if !ok {
+				return newError("argument to `next` must be an iterator, got %s", args[0].Type())
+			}
+
+			// 尽きた場合は第2引数かNULL
+			value, ok := it.Next()
+			if !ok {
+				if len(args) == 2 {
+					return args[1]
+				}
+				return NULL
+			}
+			return value
+		},
+	})
+}
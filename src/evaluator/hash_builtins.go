@@ -0,0 +1,230 @@
+package evaluator
+
+import (
+	"choco/src/object"
+)
+
+func init() {
+	registerBuiltin("keys", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"hash"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+			}
+
+			pairs := args[0].(*object.Hash).Pairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Key
+			}
+			return &object.Array{Elements: elements}
+		},
+	})
+	registerBuiltin("values", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"hash"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to `values` must be HASH, got %s", args[0].Type())
+			}
+
+			pairs := args[0].(*object.Hash).Pairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Value
+			}
+			return &object.Array{Elements: elements}
+		},
+	})
+	registerBuiltin("entries", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"hash"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to `entries` must be HASH, got %s", args[0].Type())
+			}
+
+			pairs := args[0].(*object.Hash).Pairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			}
+			return &object.Array{Elements: elements}
+		},
+	})
+	registerBuiltin("fromEntries", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"entries"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `fromEntries` must be ARRAY, got %s", args[0].Type())
+			}
+
+			hash := &object.Hash{}
+			for i, elem := range args[0].(*object.Array).Elements {
+				entry, ok := elem.(*object.Array)
+				if !ok || len(entry.Elements) != 2 {
+					return newError("entry %d of `fromEntries` must be [key, value], got %s", i, elem.Inspect())
+				}
+				if err := setPair(hash, entry.Elements[0], entry.Elements[1]); err != nil {
+					return err
+				}
+			}
+			return hash
+		},
+	})
+	registerBuiltin("has", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"hash", "key"},
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkHashable(args[1]); err != nil {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Hash:
+				_, found := arg.Get(args[1])
+				return nativeBoolToBooleanObject(found)
+			case *object.Set:
+				return nativeBoolToBooleanObject(arg.Has(args[1]))
+			default:
+				return newError("argument to `has` must be HASH or SET, got %s", args[0].Type())
+			}
+		},
+	})
+	registerBuiltin("set", &object.Builtin{
+		MinArgs: 3,
+		MaxArgs: 3,
+		Params:  []string{"hash", "key", "value"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to `set` must be HASH, got %s", args[0].Type())
+			}
+
+			// pushと同じく元のhashは変更しない
+			hash := args[0].(*object.Hash).Copy()
+			if err := setPair(hash, args[1], args[2]); err != nil {
+				return err
+			}
+			return hash
+		},
+	})
+	registerBuiltin("delete", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"hash", "key"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to `delete` must be HASH, got %s", args[0].Type())
+			}
+			if err := checkHashable(args[1]); err != nil {
+				return err
+			}
+
+			hash := args[0].(*object.Hash).Copy()
+			hash.Delete(args[1])
+			return hash
+		},
+	})
+	registerBuiltin("merge", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: -1,
+		Fn: func(args ...object.Object) object.Object {
+			return mergeHashes("merge", args, false)
+		},
+	})
+	registerBuiltin("deepMerge", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: -1,
+		Fn: func(args ...object.Object) object.Object {
+			return mergeHashes("deepMerge", args, true)
+		},
+	})
+	registerBuiltin("mapValues", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"hash", "fn"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to `mapValues` must be HASH, got %s", args[0].Type())
+			}
+
+			hash := &object.Hash{}
+			for _, pair := range args[0].(*object.Hash).Pairs() {
+				value := applyCallback(args[1], []object.Object{pair.Value, pair.Key})
+				if isError(value) {
+					return value
+				}
+				setPair(hash, pair.Key, value)
+			}
+			return hash
+		},
+	})
+	registerBuiltin("filterEntries", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"hash", "predicate"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to `filterEntries` must be HASH, got %s", args[0].Type())
+			}
+
+			hash := &object.Hash{}
+			for _, pair := range args[0].(*object.Hash).Pairs() {
+				keep := applyCallback(args[1], []object.Object{pair.Key, pair.Value})
+				if isError(keep) {
+					return keep
+				}
+				if isTruthy(keep) {
+					setPair(hash, pair.Key, pair.Value)
+				}
+			}
+			return hash
+		},
+	})
+}
+
+func setPair(hash *object.Hash, key, value object.Object) *object.Error {
+	if !hash.Set(key, value) {
+		return newError("unusable as hash key: %s", key.Type())
+	}
+	return nil
+}
+
+// 後の引数の値で上書きする. deepの場合は両方hashの値を再帰的にmergeする
+func mergeHashes(name string, args []object.Object, deep bool) object.Object {
+	for _, arg := range args {
+		if arg.Type() != object.HASH_OBJ {
+			return newError("arguments to `%s` must be HASH, got %s", name, arg.Type())
+		}
+	}
+
+	merged := args[0].(*object.Hash).Copy()
+	for _, arg := range args[1:] {
+		mergeHash(merged, arg.(*object.Hash), deep)
+	}
+	return merged
+}
+
+func mergeHash(dst, src *object.Hash, deep bool) {
+	for _, pair := range src.Pairs() {
+		if deep {
+			old, found := dst.Get(pair.Key)
+			oldHash, oldOk := old.Value.(*object.Hash)
+			newHash, newOk := pair.Value.(*object.Hash)
+			if found && oldOk && newOk {
+				nested := oldHash.Copy()
+				mergeHash(nested, newHash, true)
+				dst.Set(old.Key, nested)
+				continue
+			}
+		}
+		dst.Set(pair.Key, pair.Value)
+	}
+}
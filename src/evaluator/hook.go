@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"choco/src/ast"
+	"choco/src/object"
+)
+
+// Hook is notified while a program is evaluated. the debugger pauses the program inside its methods,
+// and tracers, profilers and coverage recorders are plugged in as hooks.
+type Hook interface {
+	// Statement is called before stmt is evaluated in env
+	Statement(stmt ast.Statement, env *object.Environment)
+	// Call is called before fn is applied to args.
+	// env is the environment of the new frame, or nil when fn is a builtin.
+	Call(call *ast.CallExpression, fn object.Object, args []object.Object, env *object.Environment)
+	// Return is called after fn returned result
+	Return(call *ast.CallExpression, fn object.Object, result object.Object)
+	// Branch is called when node chose a branch.
+	// consequence is false for the else branch, including an omitted one.
+	Branch(node *ast.IfExpression, consequence bool)
+	// Error is called when a runtime error is raised, before it propagates
+	Error(err *object.Error)
+}
+
+// nilの間はhookを呼ばない
+var hook Hook
+
+// SetHook installs h for following evaluations. pass nil to remove it.
+func SetHook(h Hook) {
+	hook = h
+}
+
+// Hooks is a Hook which notifies every hook in order
+type Hooks []Hook
+
+func (hooks Hooks) Statement(stmt ast.Statement, env *object.Environment) {
+	for _, h := range hooks {
+		h.Statement(stmt, env)
+	}
+}
+
+func (hooks Hooks) Call(call *ast.CallExpression, fn object.Object, args []object.Object, env *object.Environment) {
+	for _, h := range hooks {
+		h.Call(call, fn, args, env)
+	}
+}
+
+func (hooks Hooks) Return(call *ast.CallExpression, fn object.Object, result object.Object) {
+	// 入れ子になるように逆順で呼ぶ
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].Return(call, fn, result)
+	}
+}
+
+func (hooks Hooks) Branch(node *ast.IfExpression, consequence bool) {
+	for _, h := range hooks {
+		h.Branch(node, consequence)
+	}
+}
+
+func (hooks Hooks) Error(err *object.Error) {
+	for _, h := range hooks {
+		h.Error(err)
+	}
+}
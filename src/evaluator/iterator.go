@@ -0,0 +1,185 @@
+package evaluator
+
+import (
+	"choco/src/ast"
+	"choco/src/object"
+)
+
+func evalRangeExpression(node *ast.RangeExpression, env *object.Environment) object.Object {
+	start := Eval(node.Start, env)
+	if isError(start) {
+		return start
+	}
+	end := Eval(node.End, env)
+	if isError(end) {
+		return end
+	}
+
+	startInt, ok := start.(*object.Integer)
+	if !ok {
+		return newError("range bounds must be INTEGER, got %s%s%s", start.Type(), node.Token.Literal, end.Type())
+	}
+	endInt, ok := end.(*object.Integer)
+	if !ok {
+		return newError("range bounds must be INTEGER, got %s%s%s", start.Type(), node.Token.Literal, end.Type())
+	}
+	return &object.Range{Start: startInt.Value, End: endInt.Value, Inclusive: node.Inclusive()}
+}
+
+func iteratorOf(name string, obj object.Object) (object.Iterator, *object.Error) {
+	iterable, ok := obj.(object.Iterable)
+	if !ok {
+		return nil, newError("argument to `%s` must be iterable, got %s", name, obj.Type())
+	}
+	return iterable.Iterate(), nil
+}
+
+// toArray以外は読まれるまで元のiteratorを進めない
+func init() {
+	registerBuiltin("toArray", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"iterable"},
+		Fn: func(args ...object.Object) object.Object {
+			it, err := iteratorOf("toArray", args[0])
+			if err != nil {
+				return err
+			}
+
+			elements := []object.Object{}
+			for value, ok := it.Next(); ok; value, ok = it.Next() {
+				if isError(value) {
+					return value
+				}
+				elements = append(elements, value)
+			}
+			return &object.Array{Elements: elements}
+		},
+	})
+	registerBuiltin("take", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"iterable", "n"},
+		Fn: func(args ...object.Object) object.Object {
+			it, err := iteratorOf("take", args[0])
+			if err != nil {
+				return err
+			}
+			n, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("second argument to `take` must be INTEGER, got %s", args[1].Type())
+			}
+
+			taken := int64(0)
+			return object.NewIterator(func() (object.Object, bool) {
+				if taken >= n.Value {
+					return nil, false
+				}
+				taken++
+				return it.Next()
+			})
+		},
+	})
+	registerBuiltin("drop", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"iterable", "n"},
+		Fn: func(args ...object.Object) object.Object {
+			it, err := iteratorOf("drop", args[0])
+			if err != nil {
+				return err
+			}
+			n, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("second argument to `drop` must be INTEGER, got %s", args[1].Type())
+			}
+
+			// 最初に読まれるまで読み飛ばさない
+			dropped := false
+			return object.NewIterator(func() (object.Object, bool) {
+				if !dropped {
+					dropped = true
+					for i := int64(0); i < n.Value; i++ {
+						value, ok := it.Next()
+						if !ok || isError(value) {
+							return value, ok
+						}
+					}
+				}
+				return it.Next()
+			})
+		},
+	})
+	registerBuiltin("takeWhile", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"iterable", "predicate"},
+		Fn: func(args ...object.Object) object.Object {
+			it, err := iteratorOf("takeWhile", args[0])
+			if err != nil {
+				return err
+			}
+			fn := args[1]
+
+			return object.NewIterator(func() (object.Object, bool) {
+				value, ok := it.Next()
+				if !ok || isError(value) {
+					return value, ok
+				}
+				keep := applyFunction(nil, fn, []object.Object{value})
+				if isError(keep) {
+					return keep, true
+				}
+				if !isTruthy(keep) {
+					return nil, false
+				}
+				return value, true
+			})
+		},
+	})
+	registerBuiltin("enumerate", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"iterable"},
+		Fn: func(args ...object.Object) object.Object {
+			it, err := iteratorOf("enumerate", args[0])
+			if err != nil {
+				return err
+			}
+
+			index := int64(0)
+			return object.NewIterator(func() (object.Object, bool) {
+				value, ok := it.Next()
+				if !ok || isError(value) {
+					return value, ok
+				}
+				index++
+				return &object.Array{Elements: []object.Object{&object.Integer{Value: index - 1}, value}}, true
+			})
+		},
+	})
+	registerBuiltin("chain", &object.Builtin{
+		MinArgs: 0,
+		MaxArgs: -1,
+		Fn: func(args ...object.Object) object.Object {
+			iterators := make([]object.Iterator, 0, len(args))
+			for _, arg := range args {
+				it, err := iteratorOf("chain", arg)
+				if err != nil {
+					return err
+				}
+				iterators = append(iterators, it)
+			}
+
+			return object.NewIterator(func() (object.Object, bool) {
+				for len(iterators) > 0 {
+					if value, ok := iterators[0].Next(); ok {
+						return value, true
+					}
+					iterators = iterators[1:]
+				}
+				return nil, false
+			})
+		},
+	})
+}
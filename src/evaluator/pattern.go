@@ -0,0 +1,223 @@
+package evaluator
+
+import (
+	"choco/src/ast"
+	"choco/src/object"
+	"fmt"
+)
+
+// bindPattern takes value apart by pattern and binds the names in env.
+// default values are evaluated in env after the names on their left are bound.
+// a value which doesn't fit the pattern is an error.
+func bindPattern(pattern ast.Pattern, value object.Object, env *object.Environment) *object.Error {
+	mismatch, err := destructure(pattern, value, env)
+	if err != nil {
+		return err
+	}
+	if mismatch != "" {
+		return newError("%s", mismatch)
+	}
+	return nil
+}
+
+// valueをpatternで分解してenvに束縛する. 合わない場合はその理由を返す.
+// errorは既定値の評価などで起きたもの
+func destructure(pattern ast.Pattern, value object.Object, env *object.Environment) (string, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		env.Set(pattern.Value, value)
+		return "", nil
+	case *ast.WildcardPattern:
+		return "", nil
+	case *ast.LiteralPattern:
+		return destructureLiteral(pattern, value, env)
+	case *ast.TypePattern:
+		return destructureType(pattern, value, env)
+	case *ast.AlternativePattern:
+		// どの選択肢も同じ名前を束縛するので, 合わなかった選択肢の束縛は上書きされる
+		for _, alternative := range pattern.Alternatives {
+			mismatch, err := destructure(alternative, value, env)
+			if err != nil || mismatch == "" {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("%s does not match %s", value.Inspect(), pattern.String()), nil
+	case *ast.ArrayPattern:
+		return destructureArray(pattern, value, env)
+	case *ast.HashPattern:
+		return destructureHash(pattern, value, env)
+	default:
+		return "", newError("unknown pattern: %s", pattern.String())
+	}
+}
+
+func destructureLiteral(pattern *ast.LiteralPattern, value object.Object, env *object.Environment) (string, *object.Error) {
+	literal := Eval(pattern.Value, env)
+	if err, ok := literal.(*object.Error); ok {
+		return "", err
+	}
+	if !object.Equal(literal, value) {
+		return fmt.Sprintf("%s does not match %s", value.Inspect(), pattern.String()), nil
+	}
+	return "", nil
+}
+
+// 型patternの名前と, その型の値か
+var typePatterns = map[string]func(object.Object) bool{
+	"Integer":   isObjectType(object.INTEGER_OBJ),
+	"Boolean":   isObjectType(object.BOOLEAN_OBJ),
+	"String":    isObjectType(object.STRING_OBJ),
+	"Null":      isObjectType(object.NULL_OBJ),
+	"Array":     isObjectType(object.ARRAY_OBJ),
+	"Hash":      isObjectType(object.HASH_OBJ),
+	"Set":       isObjectType(object.SET_OBJ),
+	"Range":     isObjectType(object.RANGE_OBJ),
+	"Generator": isObjectType(object.GENERATOR_OBJ),
+	"Function": func(obj object.Object) bool {
+		return obj.Type() == object.FUNCTION_OBJ || obj.Type() == object.BUILTIN_OBJ
+	},
+	"Iterator": func(obj object.Object) bool {
+		_, ok := obj.(object.Iterator)
+		return ok
+	},
+}
+
+func isObjectType(objectType object.ObjectType) func(object.Object) bool {
+	return func(obj object.Object) bool {
+		return obj.Type() == objectType
+	}
+}
+
+// IsTypePattern reports whether name can be used as a type pattern such as `Integer(n)`
+func IsTypePattern(name string) bool {
+	_, ok := typePatterns[name]
+	return ok
+}
+
+func destructureType(pattern *ast.TypePattern, value object.Object, env *object.Environment) (string, *object.Error) {
+	isType, ok := typePatterns[pattern.Type]
+	if !ok {
+		return "", newError("unknown type %s in pattern %s", pattern.Type, pattern.String())
+	}
+	if !isType(value) {
+		return fmt.Sprintf("%s does not match %s", value.Type(), pattern.String()), nil
+	}
+	if pattern.Pattern == nil {
+		return "", nil
+	}
+	return destructure(pattern.Pattern, value, env)
+}
+
+func destructureArray(pattern *ast.ArrayPattern, value object.Object, env *object.Environment) (string, *object.Error) {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return fmt.Sprintf("cannot destructure %s with %s", value.Type(), pattern.String()), nil
+	}
+	elements := array.Elements
+	if len(elements) > len(pattern.Elements) && pattern.Rest == nil {
+		return fmt.Sprintf("too many elements to destructure with %s: got %d", pattern.String(), len(elements)), nil
+	}
+	// 既定値の評価より先に要素の数を調べる
+	for i := len(elements); i < len(pattern.Elements); i++ {
+		if pattern.Defaults[i] == nil {
+			return fmt.Sprintf("not enough elements to destructure with %s: got %d", pattern.String(), len(elements)), nil
+		}
+	}
+
+	for i, elem := range pattern.Elements {
+		if i < len(elements) {
+			if mismatch, err := destructure(elem, elements[i], env); mismatch != "" || err != nil {
+				return mismatch, err
+			}
+			continue
+		}
+		if mismatch, err := destructureDefault(elem, pattern.Defaults[i], env); mismatch != "" || err != nil {
+			return mismatch, err
+		}
+	}
+
+	if pattern.Rest != nil {
+		rest := []object.Object{}
+		if len(elements) > len(pattern.Elements) {
+			rest = append(rest, elements[len(pattern.Elements):]...)
+		}
+		env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+	}
+	return "", nil
+}
+
+// keyは文字列のkeyの名前
+func destructureHash(pattern *ast.HashPattern, value object.Object, env *object.Environment) (string, *object.Error) {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return fmt.Sprintf("cannot destructure %s with %s", value.Type(), pattern.String()), nil
+	}
+
+	for i, key := range pattern.Keys {
+		if pair, ok := hash.Get(&object.String{Value: key.Value}); ok {
+			if mismatch, err := destructure(pattern.Values[i], pair.Value, env); mismatch != "" || err != nil {
+				return mismatch, err
+			}
+			continue
+		}
+		if pattern.Defaults[i] == nil {
+			return fmt.Sprintf("missing key %s to destructure with %s", key.Value, pattern.String()), nil
+		}
+		if mismatch, err := destructureDefault(pattern.Values[i], pattern.Defaults[i], env); mismatch != "" || err != nil {
+			return mismatch, err
+		}
+	}
+
+	if pattern.Rest != nil {
+		rest := hash.Copy()
+		for _, key := range pattern.Keys {
+			rest.Delete(&object.String{Value: key.Value})
+		}
+		env.Set(pattern.Rest.Value, rest)
+	}
+	return "", nil
+}
+
+func destructureDefault(pattern ast.Pattern, defaultValue ast.Expression, env *object.Environment) (string, *object.Error) {
+	value := Eval(defaultValue, env)
+	if err, ok := value.(*object.Error); ok {
+		return "", err
+	}
+	return destructure(pattern, value, env)
+}
+
+// 腕を順に試し, 最初に合った腕の値を返す.
+// 腕ごとに環境を作り, 束縛した名前は腕の中だけで使える
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(node.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range node.Arms {
+		scope := object.NewEnclosedEnvironment(env)
+		// 腕の中のyieldは囲む関数のgeneratorに送る
+		if yield, ok := env.Yield(); ok {
+			scope.SetYield(yield)
+		}
+		mismatch, err := destructure(arm.Pattern, subject, scope)
+		if err != nil {
+			return err
+		}
+		if mismatch != "" {
+			continue
+		}
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, scope)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return Eval(arm.Value, scope)
+	}
+	return newError("no match for %s", subject.Inspect())
+}
@@ -0,0 +1,88 @@
+package evaluator
+
+import (
+	"choco/src/object"
+)
+
+func init() {
+	registerBuiltin("toSet", &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Params:  []string{"array"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `toSet` must be ARRAY, got %s", args[0].Type())
+			}
+
+			set := &object.Set{}
+			for _, elem := range args[0].(*object.Array).Elements {
+				if !set.Add(elem) {
+					return newError("unusable as set element: %s", elem.Type())
+				}
+			}
+			return set
+		},
+	})
+	registerBuiltin("add", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"set", "value"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.SET_OBJ {
+				return newError("argument to `add` must be SET, got %s", args[0].Type())
+			}
+
+			// pushと同じく元のsetは変更しない
+			set := args[0].(*object.Set).Copy()
+			if !set.Add(args[1]) {
+				return newError("unusable as set element: %s", args[1].Type())
+			}
+			return set
+		},
+	})
+	registerBuiltin("remove", &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []string{"set", "value"},
+		Fn: func(args ...object.Object) object.Object {
+			if args[0].Type() != object.SET_OBJ {
+				return newError("argument to `remove` must be SET, got %s", args[0].Type())
+			}
+			if _, ok := object.HashKeyOf(args[1]); !ok {
+				return newError("unusable as set element: %s", args[1].Type())
+			}
+
+			set := args[0].(*object.Set).Copy()
+			set.Remove(args[1])
+			return set
+		},
+	})
+	registerBuiltin("union", setOperation("union", func(a, b *object.Set) object.Object {
+		return a.Union(b)
+	}))
+	registerBuiltin("intersect", setOperation("intersect", func(a, b *object.Set) object.Object {
+		return a.Intersect(b)
+	}))
+	registerBuiltin("difference", setOperation("difference", func(a, b *object.Set) object.Object {
+		return a.Difference(b)
+	}))
+	registerBuiltin("isSubset", setOperation("isSubset", func(a, b *object.Set) object.Object {
+		return nativeBoolToBooleanObject(a.IsSubset(b))
+	}))
+}
+
+// 2つのsetを受け取るbuiltinを作る
+func setOperation(name string, op func(a, b *object.Set) object.Object) *object.Builtin {
+	return &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				if arg.Type() != object.SET_OBJ {
+					return newError("arguments to `%s` must be SET, got %s", name, arg.Type())
+				}
+			}
+			return op(args[0].(*object.Set), args[1].(*object.Set))
+		},
+	}
+}
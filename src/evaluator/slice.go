@@ -0,0 +1,129 @@
+package evaluator
+
+import (
+	"choco/src/ast"
+	"choco/src/object"
+)
+
+// trueの間は範囲外の添字やsliceの境界をNULLや切り詰めにせずerrorにする
+var strict bool
+
+// SetStrict makes out-of-range indexes and slice bounds runtime errors when on
+func SetStrict(on bool) {
+	strict = on
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	// 省略された部分はnilのまま
+	bounds := make([]*int64, 3)
+	for i, expr := range []ast.Expression{node.Start, node.End, node.Step} {
+		if expr == nil {
+			continue
+		}
+		value := Eval(expr, env)
+		if isError(value) {
+			return value
+		}
+		integer, ok := value.(*object.Integer)
+		if !ok {
+			return newError("slice index must be INTEGER, got %s", value.Type())
+		}
+		bounds[i] = &integer.Value
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		indexes, err := sliceIndexes(len(left.Elements), bounds[0], bounds[1], bounds[2])
+		if err != nil {
+			return err
+		}
+		elements := make([]object.Object, 0, len(indexes))
+		for _, i := range indexes {
+			elements = append(elements, left.Elements[i])
+		}
+		return &object.Array{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		indexes, err := sliceIndexes(len(runes), bounds[0], bounds[1], bounds[2])
+		if err != nil {
+			return err
+		}
+		sliced := make([]rune, 0, len(indexes))
+		for _, i := range indexes {
+			sliced = append(sliced, runes[i])
+		}
+		return &object.String{Value: string(sliced)}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// 取り出す要素の添字を並べる. 境界はPythonと同じく負なら末尾から数え, 範囲外は切り詰める.
+// stepが負の場合は逆順に辿り, 省略された境界は末尾から先頭までになる
+func sliceIndexes(length int, start, end, step *int64) ([]int, *object.Error) {
+	n := int64(length)
+	by := int64(1)
+	if step != nil {
+		by = *step
+	}
+	if by == 0 {
+		return nil, newError("slice step cannot be zero")
+	}
+
+	// stepが負の場合は-1が先頭の手前を表す
+	low, high := int64(0), n
+	omittedStart, omittedEnd := low, high
+	if by < 0 {
+		low, high = -1, n-1
+		omittedStart, omittedEnd = high, low
+	}
+	bound := func(b *int64, omitted int64) (int64, *object.Error) {
+		if b == nil {
+			return omitted, nil
+		}
+		v := *b
+		if v < 0 {
+			v += n
+		}
+		if v < low || v > high {
+			if strict {
+				return 0, newError("slice bounds out of range: %d with length %d", *b, length)
+			}
+			if v < low {
+				v = low
+			} else {
+				v = high
+			}
+		}
+		return v, nil
+	}
+
+	from, err := bound(start, omittedStart)
+	if err != nil {
+		return nil, err
+	}
+	to, err := bound(end, omittedEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// i += byで進めるとstepが大きい場合に溢れるので, 要素の数を先に求める
+	distance, size := to-from, uint64(by)
+	if by < 0 {
+		distance, size = from-to, uint64(-(by+1))+1
+	}
+	indexes := []int{}
+	if distance <= 0 {
+		return indexes, nil
+	}
+	count := (uint64(distance)-1)/size + 1
+	for k := int64(0); uint64(k) < count; k++ {
+		indexes = append(indexes, int(from+k*by))
+	}
+	return indexes, nil
+}
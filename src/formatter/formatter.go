@@ -0,0 +1,194 @@
+package formatter
+
+import (
+	"choco/src/ast"
+	"choco/src/lexer"
+	"choco/src/parser"
+	"choco/src/token"
+	"errors"
+	"strings"
+)
+
+const (
+	INDENT    = "    "
+	MAX_WIDTH = 80
+)
+
+// Format returns input in the canonical style.
+// input must be parsed without errors. comments are kept.
+func Format(input string) (string, error) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	f := &formatter{
+		lines:    strings.Split(input, "\n"),
+		comments: l.Comments(),
+	}
+	// Programの末尾は入力の終端
+	end := token.Token{Line: len(f.lines) + 1, Column: 1}
+	out := f.list(toItems(program.Statements), 0, end)
+	if out.opening != "" {
+		out.text = out.opening + "\n" + out.text
+	}
+	// shebangはlexerが読み飛ばすのでそのまま残す
+	if strings.HasPrefix(input, "#!") {
+		out.text = strings.TrimRight(f.lines[0], " \t\r") + "\n" + out.text
+	}
+	if strings.TrimSpace(out.text) == "" {
+		return "", nil
+	}
+	return strings.TrimRight(out.text, "\n") + "\n", nil
+}
+
+type formatter struct {
+	lines    []string      // 入力の各行. 空行とcommentの種類の判定に使う
+	comments []token.Token // まだ出力していないcomment
+}
+
+// 文または要素の並びを1要素として扱う
+type item struct {
+	node      ast.Node
+	render    func(indent int) string // nilの場合は文として出力する
+	separator string
+}
+
+func toItems(stmts []ast.Statement) []item {
+	items := []item{}
+	for _, stmt := range stmts {
+		items = append(items, item{node: stmt})
+	}
+	return items
+}
+
+// list()の結果
+type listOutput struct {
+	opening string // 開き括弧の直後に置くcomment
+	text    string // 各行を改行で連結したもの. 各行はindent済み
+}
+
+// itemsを1行ずつ並べる. endより前にあるcommentはこの中に出力する
+func (f *formatter) list(items []item, indent int, end token.Token) listOutput {
+	type entry struct {
+		blankBefore bool
+		leading     []string
+		text        string
+		trailing    string
+		separator   string
+	}
+
+	var out listOutput
+	entries := []*entry{}
+	prefix := strings.Repeat(INDENT, indent)
+
+	// commentを直前の要素の行末か, 次の要素の前の行に振り分ける
+	attach := func(comments []token.Token, next *entry) {
+		for _, c := range comments {
+			if f.isTrailing(c) {
+				if len(entries) == 0 {
+					out.opening = joinComment(out.opening, c.Literal)
+				} else {
+					prev := entries[len(entries)-1]
+					prev.trailing = joinComment(prev.trailing, c.Literal)
+				}
+				continue
+			}
+
+			line := prefix + c.Literal
+			if f.isBlankBefore(c.Line) && (len(entries) > 0 || len(next.leading) > 0) {
+				line = "\n" + line
+			}
+			next.leading = append(next.leading, line)
+		}
+	}
+
+	for _, it := range items {
+		e := &entry{}
+		start := ast.Start(it.node)
+		attach(f.takeComments(start), e)
+		e.blankBefore = (len(entries) > 0 || len(e.leading) > 0) && f.isBlankBefore(start.Line)
+		e.separator = it.separator
+		if it.render != nil {
+			e.text = prefix + it.render(indent)
+		} else {
+			e.text = prefix + f.statement(it.node.(ast.Statement), indent)
+		}
+		entries = append(entries, e)
+	}
+
+	closing := &entry{}
+	attach(f.takeComments(ast.Position{Line: end.Line, Column: end.Column}), closing)
+
+	// 次の文の先頭によっては前の文に続く式として解釈されてしまうので;で区切る
+	for i := 0; i+1 < len(entries); i++ {
+		if _, ok := items[i].node.(ast.Statement); ok && continuesPrevious(strings.TrimLeft(entries[i+1].text, " ")) {
+			entries[i].separator = ";"
+		}
+	}
+
+	lines := []string{}
+	for _, e := range entries {
+		lines = append(lines, e.leading...)
+		text := e.text + e.separator
+		if e.trailing != "" {
+			text += " " + e.trailing
+		}
+		if e.blankBefore {
+			text = "\n" + text
+		}
+		lines = append(lines, text)
+	}
+	lines = append(lines, closing.leading...)
+
+	out.text = strings.Join(lines, "\n")
+	return out
+}
+
+func continuesPrevious(text string) bool {
+	return strings.HasPrefix(text, "(") || strings.HasPrefix(text, "[") || strings.HasPrefix(text, "-")
+}
+
+func joinComment(current, comment string) string {
+	if current == "" {
+		return comment
+	}
+	return current + " " + comment
+}
+
+// posより前にあるcommentを取り出す
+func (f *formatter) takeComments(pos ast.Position) []token.Token {
+	i := 0
+	for i < len(f.comments) && (ast.Position{Line: f.comments[i].Line, Column: f.comments[i].Column}).Before(pos) {
+		i++
+	}
+	taken := f.comments[:i]
+	f.comments = f.comments[i:]
+	return taken
+}
+
+// startからendの間にcommentがあるか
+func (f *formatter) hasCommentsBetween(start ast.Position, end token.Token) bool {
+	endPos := ast.Position{Line: end.Line, Column: end.Column}
+	for _, c := range f.comments {
+		pos := ast.Position{Line: c.Line, Column: c.Column}
+		if pos.Before(start) {
+			continue
+		}
+		return pos.Before(endPos)
+	}
+	return false
+}
+
+// 同じ行のcommentより前にコードがあるか
+func (f *formatter) isTrailing(c token.Token) bool {
+	line := f.lines[c.Line-1]
+	return strings.TrimSpace(line[:c.Column-1]) != ""
+}
+
+// 入力でlineの直前の行が空行か
+func (f *formatter) isBlankBefore(line int) bool {
+	return line >= 2 && line-2 < len(f.lines) && strings.TrimSpace(f.lines[line-2]) == ""
+}
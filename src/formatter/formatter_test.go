@@ -0,0 +1,150 @@
+package formatter
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// examples/*.choco と testdata/*.input を整形した結果を testdata/*.golden と比較する
+func TestGoldenFiles(t *testing.T) {
+	examples, err := filepath.Glob("../../examples/*.choco")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs, err := filepath.Glob("testdata/*.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range append(examples, inputs...) {
+		base := filepath.Base(path)
+		golden := filepath.Join("testdata", strings.TrimSuffix(base, filepath.Ext(base))+".golden")
+
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatted, err := Format(string(src))
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+
+		if *update {
+			if err := ioutil.WriteFile(golden, []byte(formatted), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if formatted != string(expected) {
+			t.Errorf("%s: formatted output differs from %s. got=\n%s", path, golden, formatted)
+		}
+
+		again, err := Format(formatted)
+		if err != nil {
+			t.Errorf("%s: formatted output can't be parsed: %s", path, err)
+			continue
+		}
+		if again != formatted {
+			t.Errorf("%s: formatting is not idempotent. got=\n%s", path, again)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3;", "let x = 1 + 2 * 3\n"},
+		{"(1+2)*3", "(1 + 2) * 3\n"},
+		{"1-(2-3)", "1 - (2 - 3)\n"},
+		{"(1-2)-3", "1 - 2 - 3\n"},
+		{"-(1+2)", "-(1 + 2)\n"},
+		{"(-a)[0]", "(-a)[0]\n"},
+		{"-a[0]", "-a[0]\n"},
+		{"f(x)[0](y)", "f(x)[0](y)\n"},
+		{"a;(b+c)*2", "a;\n(b + c) * 2\n"},
+		{"a;[1]", "a;\n[1]\n"},
+		{"a;-1", "a;\n-1\n"},
+		{"a\n\n\n\nb", "a\n\nb\n"},
+		{"if(x){1}else{2}", "if (x) {\n    1\n} else {\n    2\n}\n"},
+		{"[\n1, 2]", "[\n    1,\n    2,\n]\n"},
+		{"[1,\n2]", "[1, 2]\n"},
+		{"{}", "{}\n"},
+		{"a[1:n+1]", "a[1:n + 1]\n"},
+		{"0 .. n+1", "0..n + 1\n"},
+		{"(0..<n)==r", "0..<n == r\n"},
+		{"(0..1)..2", "(0..1)..2\n"},
+		{"a[ : :-1]", "a[::-1]\n"},
+		{"(-a)[1:]", "(-a)[1:]\n"},
+		{"#{1,2}|#{3}&s", "#{1, 2} | #{3} & s\n"},
+		{"(#{1}|s)&t", "(#{1} | s) & t\n"},
+		{"for(x in 0..<n){puts(x)}", "for (x in 0..<n) {\n    puts(x)\n}\n"},
+		{"fn(){yield 1+2;}", "fn() {\n    yield 1 + 2\n}\n"},
+		{"fn(a,b=1+2,...c){a}", "fn(a, b = 1 + 2, ...c) {\n    a\n}\n"},
+		{"f(...xs,1)", "f(...xs, 1)\n"},
+		{"render(u,compact:true)", "render(u, compact: true)\n"},
+		{"let [a,b=1,...r]=x", "let [a, b = 1, ...r] = x\n"},
+		{"let {name,age:a=0,...r}=u", "let {name, age: a = 0, ...r} = u\n"},
+		{"for([k,v] in xs){k}", "for ([k, v] in xs) {\n    k\n}\n"},
+		{"match(x){1|-2=>a,[h,...t] if h>0=>h,Integer(n)=>n,_=>0}", "match (x) {\n    1 | -2 => a,\n    [h, ...t] if h > 0 => h,\n    Integer(n) => n,\n    _ => 0,\n}\n"},
+		{"match(x){\n// empty\n[]=>0 // none\n}", "match (x) {\n    // empty\n    [] => 0, // none\n}\n"},
+		{"let f=fn(x){match(x){{name}=>if(name){1}else{2}}}", "let f = fn(x) {\n    match (x) {\n        {name} => if (name) {\n            1\n        } else {\n            2\n        },\n    }\n}\n"},
+		{"match(x){}", "match (x) {}\n"},
+		{"fn({a}={}){a}", "fn({a} = {}) {\n    a\n}\n"},
+		{"", ""},
+		{"// only comment", "// only comment\n"},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Format(tt.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if formatted != tt.expected {
+			t.Errorf("%q: wrong output. want=%q, got=%q", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestFormatLongLiteral(t *testing.T) {
+	input := `let names = ["aaaaaaaaaa", "bbbbbbbbbb", "cccccccccc", "dddddddddd", "eeeeeeeeee", "ffffffffff"]`
+	expected := `let names = [
+    "aaaaaaaaaa",
+    "bbbbbbbbbb",
+    "cccccccccc",
+    "dddddddddd",
+    "eeeeeeeeee",
+    "ffffffffff",
+]
+`
+	formatted, err := Format(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted != expected {
+		t.Errorf("wrong output. got=\n%s", formatted)
+	}
+}
+
+func TestFormatParseError(t *testing.T) {
+	_, err := Format("let = 1")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if !strings.HasPrefix(err.Error(), "line 1: ") {
+		t.Errorf("wrong error. got=%q", err.Error())
+	}
+}
@@ -0,0 +1,258 @@
+package formatter
+
+import (
+	"choco/src/ast"
+	"choco/src/parser"
+	"choco/src/token"
+	"strings"
+)
+
+func (f *formatter) statement(stmt ast.Statement, indent int) string {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if stmt.Pattern != nil {
+			return "let " + f.pattern(stmt.Pattern, indent) + " = " + f.expression(stmt.Value, indent)
+		}
+		return "let " + stmt.Name.Value + " = " + f.expression(stmt.Value, indent)
+	case *ast.ReturnStatement:
+		return "return " + f.expression(stmt.ReturnValue, indent)
+	case *ast.YieldStatement:
+		return "yield " + f.expression(stmt.Value, indent)
+	case *ast.ExpressionStatement:
+		return f.expression(stmt.Expression, indent)
+	default:
+		return stmt.String()
+	}
+}
+
+// 複数行になる場合, 2行目以降はindentを含めて返す
+func (f *formatter) expression(expr ast.Expression, indent int) string {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		return expr.Value
+	case *ast.IntegerLiteral:
+		return expr.Token.Literal
+	case *ast.StringLiteral:
+		return "\"" + expr.Value + "\""
+	case *ast.Boolean:
+		return expr.Token.Literal
+	case *ast.PrefixExpression:
+		return expr.Operator + f.operand(expr.Right, parser.PREFIX, indent)
+	case *ast.InfixExpression:
+		precedence := parser.PrecedenceOf(expr.Token.Type)
+		// 左結合なので右辺は同じ強さでも括弧が必要
+		return f.operand(expr.Left, precedence, indent) + " " + expr.Operator + " " + f.operand(expr.Right, precedence+1, indent)
+	case *ast.RangeExpression:
+		return f.operand(expr.Start, parser.RANGE+1, indent) + expr.Token.Literal + f.operand(expr.End, parser.RANGE+1, indent)
+	case *ast.IfExpression:
+		out := "if (" + f.expression(expr.Condition, indent) + ") " + f.block(expr.Consequence, indent)
+		if expr.Alternative != nil {
+			out += " else " + f.block(expr.Alternative, indent)
+		}
+		return out
+	case *ast.ForExpression:
+		return "for (" + f.pattern(expr.Variable, indent) + " in " + f.expression(expr.Iterable, indent) + ") " + f.block(expr.Body, indent)
+	case *ast.MatchExpression:
+		return "match (" + f.expression(expr.Subject, indent) + ") " + f.arms(expr, indent)
+	case *ast.FunctionLiteral:
+		params := []string{}
+		for i, param := range expr.Parameters {
+			params = append(params, f.withDefault(param, expr.Default(i), indent))
+		}
+		if expr.Rest != nil {
+			params = append(params, "..."+expr.Rest.Value)
+		}
+		return "fn(" + strings.Join(params, ", ") + ") " + f.block(expr.Body, indent)
+	case *ast.CallExpression:
+		return f.postfixOperand(expr.Function, indent) + "(" + f.expressions(expr.Arguments, indent) + ")"
+	case *ast.SpreadExpression:
+		return "..." + f.expression(expr.Value, indent)
+	case *ast.NamedArgument:
+		return expr.Name.Value + ": " + f.expression(expr.Value, indent)
+	case *ast.IndexExpression:
+		return f.postfixOperand(expr.Left, indent) + "[" + f.expression(expr.Index, indent) + "]"
+	case *ast.SliceExpression:
+		bounds := []string{}
+		for _, bound := range []ast.Expression{expr.Start, expr.End, expr.Step} {
+			if bound == nil {
+				bounds = append(bounds, "")
+			} else {
+				bounds = append(bounds, f.expression(bound, indent))
+			}
+		}
+		if expr.Step == nil {
+			bounds = bounds[:2]
+		}
+		return f.postfixOperand(expr.Left, indent) + "[" + strings.Join(bounds, ":") + "]"
+	case *ast.ArrayLiteral:
+		return f.elements(expr, expr.Elements, "[", "]", indent, expr.EndToken)
+	case *ast.HashLiteral:
+		return f.elements(expr, expr.Keys, "{", "}", indent, expr.EndToken)
+	case *ast.SetLiteral:
+		return f.elements(expr, expr.Elements, "#{", "}", indent, expr.EndToken)
+	default:
+		return expr.String()
+	}
+}
+
+// patternは常に1行にする
+func (f *formatter) pattern(pattern ast.Pattern, indent int) string {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return pattern.Value
+	case *ast.ArrayPattern:
+		elements := []string{}
+		for i, elem := range pattern.Elements {
+			elements = append(elements, f.withDefault(elem, pattern.Defaults[i], indent))
+		}
+		if pattern.Rest != nil {
+			elements = append(elements, "..."+pattern.Rest.Value)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *ast.HashPattern:
+		entries := []string{}
+		for i, key := range pattern.Keys {
+			entry := f.withDefault(pattern.Values[i], pattern.Defaults[i], indent)
+			if ident, ok := pattern.Values[i].(*ast.Identifier); !ok || ident.Value != key.Value {
+				entry = key.Value + ": " + entry
+			}
+			entries = append(entries, entry)
+		}
+		if pattern.Rest != nil {
+			entries = append(entries, "..."+pattern.Rest.Value)
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	case *ast.WildcardPattern:
+		return "_"
+	case *ast.LiteralPattern:
+		return f.expression(pattern.Value, indent)
+	case *ast.TypePattern:
+		if pattern.Pattern == nil {
+			return pattern.Type + "()"
+		}
+		return pattern.Type + "(" + f.pattern(pattern.Pattern, indent) + ")"
+	case *ast.AlternativePattern:
+		alternatives := []string{}
+		for _, alternative := range pattern.Alternatives {
+			alternatives = append(alternatives, f.pattern(alternative, indent))
+		}
+		return strings.Join(alternatives, " | ")
+	default:
+		return pattern.String()
+	}
+}
+
+// matchの腕は常に1腕1行にする
+func (f *formatter) arms(expr *ast.MatchExpression, indent int) string {
+	items := []item{}
+	for _, arm := range expr.Arms {
+		arm := arm
+		items = append(items, item{
+			node: arm.Pattern,
+			render: func(indent int) string {
+				out := f.pattern(arm.Pattern, indent)
+				if arm.Guard != nil {
+					out += " if " + f.expression(arm.Guard, indent)
+				}
+				return out + " => " + f.expression(arm.Value, indent)
+			},
+			separator: ",",
+		})
+	}
+	return enclose("{", "}", f.list(items, indent+1, expr.EndToken), indent)
+}
+
+func (f *formatter) withDefault(pattern ast.Pattern, value ast.Expression, indent int) string {
+	if value == nil {
+		return f.pattern(pattern, indent)
+	}
+	return f.pattern(pattern, indent) + " = " + f.expression(value, indent)
+}
+
+func (f *formatter) expressions(exprs []ast.Expression, indent int) string {
+	texts := []string{}
+	for _, expr := range exprs {
+		texts = append(texts, f.expression(expr, indent))
+	}
+	return strings.Join(texts, ", ")
+}
+
+// 演算子の被演算子. 結合が弱い式は括弧で囲む
+func (f *formatter) operand(expr ast.Expression, precedence int, indent int) string {
+	text := f.expression(expr, indent)
+	if precedenceOf(expr) < precedence {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// 呼び出しや添字の対象. 前置・中置の式は括弧で囲む
+func (f *formatter) postfixOperand(expr ast.Expression, indent int) string {
+	return f.operand(expr, parser.PREFIX+1, indent)
+}
+
+func precedenceOf(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return parser.PrecedenceOf(expr.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.RangeExpression:
+		return parser.RANGE
+	default:
+		// それ以上分解されない式
+		return parser.INDEX + 1
+	}
+}
+
+func (f *formatter) block(block *ast.BlockStatement, indent int) string {
+	out := f.list(toItems(block.Statements), indent+1, block.EndToken)
+	return enclose("{", "}", out, indent)
+}
+
+func enclose(open string, close string, out listOutput, indent int) string {
+	if out.opening != "" {
+		open += " " + out.opening
+	}
+	if out.text == "" {
+		if out.opening != "" {
+			return open + "\n" + strings.Repeat(INDENT, indent) + close
+		}
+		return open + close
+	}
+	return open + "\n" + out.text + "\n" + strings.Repeat(INDENT, indent) + close
+}
+
+// array/hash/set literal. 入力で改行されていた, 長すぎる, commentを含む場合は1要素1行にする
+func (f *formatter) elements(literal ast.Expression, exprs []ast.Expression, open string, close string, indent int, end token.Token) string {
+	render := func(expr ast.Expression, indent int) string {
+		if hash, ok := literal.(*ast.HashLiteral); ok {
+			return f.expression(expr, indent) + ": " + f.expression(hash.Pairs[expr], indent)
+		}
+		return f.expression(expr, indent)
+	}
+
+	start := ast.Start(literal)
+	multiline := len(exprs) > 0 && ast.Start(exprs[0]).Line > start.Line
+	if !multiline && !f.hasCommentsBetween(start, end) {
+		texts := []string{}
+		for _, expr := range exprs {
+			texts = append(texts, render(expr, indent))
+		}
+		oneLine := open + strings.Join(texts, ", ") + close
+		if !strings.Contains(oneLine, "\n") && len(INDENT)*indent+len(oneLine) <= MAX_WIDTH {
+			return oneLine
+		}
+	}
+
+	items := []item{}
+	for _, expr := range exprs {
+		expr := expr
+		items = append(items, item{
+			node:      expr,
+			render:    func(indent int) string { return render(expr, indent) },
+			separator: ",",
+		})
+	}
+	return enclose(open, close, f.list(items, indent+1, end), indent)
+}
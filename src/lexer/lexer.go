@@ -1,25 +1,50 @@
 package lexer
 
-import "choco/src/token"
+import (
+	"choco/src/token"
+	"strings"
+)
 
 type Lexer struct {
 	input        string // 保持するprogram
 	position     int    // 現在位置. 読み込み済み
 	readPosition int    // これから読み込む位置
 	ch           byte   // 現在検査中の文字
+	line         int    // chの行番号
+	column       int    // chの列番号
+
+	comments []token.Token // 読み飛ばした `//` comment
 }
 
 func New(input string) *Lexer {
 	// goではpointerを返すには一旦変数に入れる. 参照返しているのかな?
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar() // 初期化
+	l.skipShebang()
 	return l
 }
 
+// `#!/usr/bin/env choco` のような先頭行を読み飛ばす
+// 改行文字は残すので行番号はずれない
+func (l *Lexer) skipShebang() {
+	if l.ch != '#' || l.peekChar() != '!' {
+		return
+	}
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
 // 次の1文字を読んで進める
 // この言語はascii文字だけからなると想定している. よってbyte単位で読み勧めて構わない
 // マルチバイト文字を使うにはruneにする
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // null文字
 	} else {
@@ -35,6 +60,9 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	// tokenの開始位置を記録する
+	line, column := l.line, l.column
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -42,6 +70,9 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -62,6 +93,34 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
+	case '|':
+		tok = newToken(token.PIPE, l.ch)
+	case '&':
+		tok = newToken(token.AMPERSAND, l.ch)
+	case '.':
+		if l.peekChar() == '.' {
+			l.readChar()
+			if l.peekChar() == '<' {
+				l.readChar()
+				tok = token.Token{Type: token.DOTDOTLT, Literal: "..<"}
+			} else if l.peekChar() == '.' {
+				l.readChar()
+				tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+			} else {
+				tok = token.Token{Type: token.DOTDOT, Literal: ".."}
+			}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '#':
+		if l.peekChar() == '{' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.SET_LBRACE, Literal: literal}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '<':
 		// tok = newToken(token.LT, l.ch)
 		if l.peekChar() == '=' {
@@ -110,10 +169,12 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line, tok.Column = line, column
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Line, tok.Column = line, column
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -122,6 +183,7 @@ func (l *Lexer) NextToken() token.Token {
 
 	// 1byteのtoken飲みの前提. space虫もない
 	l.readChar()
+	tok.Line, tok.Column = line, column
 	return tok
 }
 
@@ -129,10 +191,33 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
+// 空白とcommentを読み飛ばす
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		if l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+			l.readChar()
+		} else if l.ch == '/' && l.peekChar() == '/' {
+			l.readComment()
+		} else {
+			return
+		}
+	}
+}
+
+// 行末までをcommentとして記録する. 改行文字は残す
+func (l *Lexer) readComment() {
+	tok := token.Token{Type: token.COMMENT, Line: l.line, Column: l.column}
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	tok.Literal = strings.TrimRight(l.input[position:l.position], " \t\r")
+	l.comments = append(l.comments, tok)
+}
+
+// Comments returns the comments skipped so far, in source order
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -163,3 +163,161 @@ if (5 < 10) {
 	}
 
 }
+
+func TestShebangAndPosition(t *testing.T) {
+	input := `#!/usr/bin/env choco
+let x = 5;
+  "a
+b" x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "x", 2, 5},
+		{token.ASSIGN, "=", 2, 7},
+		{token.INT, "5", 2, 9},
+		{token.SEMICOLON, ";", 2, 10},
+		{token.STRING, "a\nb", 3, 3},
+		{token.IDENT, "x", 4, 4},
+		{token.EOF, "", 4, 5},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected %q, got %q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - tokenliteral wrong. expected %q, got %q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected %d:%d, got %d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestSetTokens(t *testing.T) {
+	input := "#{1} | a & b # c"
+
+	expected := []token.Token{
+		{Type: token.SET_LBRACE, Literal: "#{"},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.RBRACE, Literal: "}"},
+		{Type: token.PIPE, Literal: "|"},
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.AMPERSAND, Literal: "&"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.ILLEGAL, Literal: "#"},
+		{Type: token.IDENT, Literal: "c"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tests[%d] - wrong token. expected %q(%q), got %q(%q)", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestRangeTokens(t *testing.T) {
+	input := "0..10 0..<n . x ...rest"
+
+	expected := []token.Token{
+		{Type: token.INT, Literal: "0"},
+		{Type: token.DOTDOT, Literal: ".."},
+		{Type: token.INT, Literal: "10"},
+		{Type: token.INT, Literal: "0"},
+		{Type: token.DOTDOTLT, Literal: "..<"},
+		{Type: token.IDENT, Literal: "n"},
+		{Type: token.ILLEGAL, Literal: "."},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ELLIPSIS, Literal: "..."},
+		{Type: token.IDENT, Literal: "rest"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tests[%d] - wrong token. expected %q(%q), got %q(%q)", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	input := "match (x) { 1 | 2 => a, _ => b == c }"
+
+	expected := []token.Token{
+		{Type: token.MATCH, Literal: "match"},
+		{Type: token.LPAREN, Literal: "("},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.LBRACE, Literal: "{"},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.PIPE, Literal: "|"},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.ARROW, Literal: "=>"},
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.COMMA, Literal: ","},
+		{Type: token.IDENT, Literal: "_"},
+		{Type: token.ARROW, Literal: "=>"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.EQ, Literal: "=="},
+		{Type: token.IDENT, Literal: "c"},
+		{Type: token.RBRACE, Literal: "}"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tests[%d] - wrong token. expected %q(%q), got %q(%q)", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 10 / 2; // trailing "quoted"
+"// not a comment"
+//`
+
+	expectedTokens := []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.INT, token.SLASH, token.INT, token.SEMICOLON,
+		token.STRING, token.EOF,
+	}
+
+	l := New(input)
+	for i, expected := range expectedTokens {
+		tok := l.NextToken()
+		if tok.Type != expected {
+			t.Fatalf("tests[%d] - tokentype wrong. expected %q, got %q", i, expected, tok.Type)
+		}
+	}
+
+	expectedComments := []token.Token{
+		{Type: token.COMMENT, Literal: "// leading", Line: 1, Column: 1},
+		{Type: token.COMMENT, Literal: `// trailing "quoted"`, Line: 2, Column: 17},
+		{Type: token.COMMENT, Literal: "//", Line: 4, Column: 1},
+	}
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("wrong number of comments. expected %d, got %d", len(expectedComments), len(comments))
+	}
+	for i, expected := range expectedComments {
+		if comments[i] != expected {
+			t.Errorf("comments[%d] wrong. expected %+v, got %+v", i, expected, comments[i])
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package linter
+
+import (
+	"choco/src/ast"
+	"choco/src/lexer"
+	"choco/src/parser"
+	"fmt"
+	"sort"
+)
+
+// rule名
+const (
+	SYNTAX           = "syntax"
+	UNDEFINED        = "undefined"
+	UNUSED           = "unused"
+	SHADOWED_BUILTIN = "shadowed-builtin"
+	UNREACHABLE      = "unreachable"
+	NOT_CALLABLE     = "not-callable"
+	ARITY            = "arity"
+	DUPLICATE_KEY    = "duplicate-key"
+	NAMED_ARGUMENT   = "named-argument"
+	NON_EXHAUSTIVE   = "non-exhaustive"
+)
+
+// Rules lists every rule with its description. syntax errors are always reported.
+var Rules = map[string]string{
+	UNDEFINED:        "use of an identifier which is not defined",
+	UNUSED:           "let binding or parameter which is never used. names starting with _ are ignored",
+	SHADOWED_BUILTIN: "let binding or parameter which hides a builtin function",
+	UNREACHABLE:      "statement after return",
+	NOT_CALLABLE:     "call of a value which is not a function",
+	ARITY:            "call with a wrong number of arguments",
+	DUPLICATE_KEY:    "hash literal with the same key twice",
+	NAMED_ARGUMENT:   "named argument which the function doesn't have or which is given twice",
+	NON_EXHAUSTIVE:   "match without an arm which matches every value",
+}
+
+// Diagnostic is a problem found by the linter
+type Diagnostic struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", d.Line, d.Column, d.Message, d.Rule)
+}
+
+// Config selects rules to run
+type Config struct {
+	Rules   map[string]bool // 有効なrule. nilの場合は全て有効
+	Globals []string        // 実行時に定義済みの名前
+}
+
+// DefaultConfig enables every rule and knows the globals defined by runner
+func DefaultConfig() Config {
+	return Config{Globals: []string{"args"}}
+}
+
+func (c Config) enabled(rule string) bool {
+	if rule == SYNTAX || c.Rules == nil {
+		return true
+	}
+	return c.Rules[rule]
+}
+
+// LintSource parses input and lints it. syntax errors are reported as diagnostics.
+func LintSource(input string, config Config) []Diagnostic {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.ParseErrors()) != 0 {
+		diagnostics := []Diagnostic{}
+		for _, err := range p.ParseErrors() {
+			diagnostics = append(diagnostics, Diagnostic{
+				Rule:    SYNTAX,
+				Message: err.Message,
+				Line:    err.Token.Line,
+				Column:  err.Token.Column,
+			})
+		}
+		return diagnostics
+	}
+
+	return Lint(program, config)
+}
+
+// Lint checks program and returns diagnostics sorted by position
+func Lint(program *ast.Program, config Config) []Diagnostic {
+	l := &linter{config: config, diagnostics: []Diagnostic{}}
+	global := newScope(nil)
+	for _, name := range config.Globals {
+		global.declare(name, ast.Position{}, GLOBAL, nil).used = true
+	}
+
+	l.walkFunction(global, program.Statements)
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		return ast.Position{Line: a.Line, Column: a.Column}.Before(ast.Position{Line: b.Line, Column: b.Column})
+	})
+	return l.diagnostics
+}
+
+type linter struct {
+	config      Config
+	diagnostics []Diagnostic
+}
+
+func (l *linter) report(rule string, pos ast.Position, format string, a ...interface{}) {
+	if !l.config.enabled(rule) {
+		return
+	}
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Rule:    rule,
+		Message: fmt.Sprintf(format, a...),
+		Line:    pos.Line,
+		Column:  pos.Column,
+	})
+}
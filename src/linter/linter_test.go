@@ -0,0 +1,113 @@
+package linter
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; puts(x)", []string{}},
+		{"puts(y)", []string{"1:6: identifier not found: y (undefined)"}},
+		{"puts(args)", []string{}},
+		{"let x = 1;", []string{"1:5: let x is declared but never used (unused)"}},
+		{"let f = fn(a, _b) { a }; f(1, 2)", []string{}},
+		{"let f = fn(a, b) { a }; f(1, 2)", []string{"1:15: parameter b is declared but never used (unused)"}},
+		{"let len = fn(x) { x }; len(1)", []string{"1:5: let len shadows the builtin function (shadowed-builtin)"}},
+		{"let f = fn(first) { first }; f(1)", []string{"1:12: parameter first shadows the builtin function (shadowed-builtin)"}},
+		{"let f = fn() { return 1; puts(2); puts(3) }; f()", []string{"1:26: unreachable code after return (unreachable)"}},
+		{"let f = fn(x) { if (x) { return 1 } else { return 2 }; x }; f(1)", []string{"1:56: unreachable code after return (unreachable)"}},
+		{"let f = fn(x) { if (x) { return 1 }; x }; f(1)", []string{}},
+		{"5(1)", []string{"1:1: not a function: INTEGER (not-callable)"}},
+		{"let s = \"str\"; s()", []string{"1:16: not a function: STRING (not-callable)"}},
+		{"let plus = fn(a, b) { a + b }; plus(1)", []string{"1:32: wrong number of arguments to plus. got=1, want=2 (arity)"}},
+		{"fn(a) { a }(1, 2)", []string{"1:1: wrong number of arguments to fn(a)a. got=2, want=1 (arity)"}},
+		{"let f = fn(a, b = a) { b }; f(1); f(1, 2)", []string{}},
+		{"let f = fn(a, b = 1) { a + b }; f()", []string{"1:33: wrong number of arguments to f. got=0, want=1 to 2 (arity)"}},
+		{"let f = fn(_a, ...r) { r }; f(1, 2, 3); f()", []string{"1:41: wrong number of arguments to f. got=0, want=1 or more (arity)"}},
+		{"let f = fn(a, b) { a + b }; f(...[1, 2])", []string{}},
+		{"let f = fn(a = c) { a }; f()", []string{"1:16: identifier not found: c (undefined)"}},
+		{"let f = fn(a, b = 1) { a + b }; f(b: 2, a: 1)", []string{}},
+		{"let f = fn(a, b = 1) { a + b }; f(1, c: 2)", []string{"1:38: f has no parameter named c (named-argument)"}},
+		{"let f = fn(a, b = 1) { a + b }; f(1, a: 2)", []string{"1:38: argument a to f is given more than once (named-argument)"}},
+		{"next(take(0..3, n: 1), default: 0); len(x: 1)", []string{"1:41: len has no parameter named x (named-argument)"}},
+		{"let [a, b] = [1, 2]; puts(a)", []string{"1:9: let b is declared but never used (unused)"}},
+		{"let {name: n, ...others} = {}; puts(others)", []string{"1:12: let n is declared but never used (unused)"}},
+		{"let f = fn([a, b = a]) { b }; f([1])", []string{}},
+		{"let f = fn([a, b = c]) { a + b }; f([1])", []string{"1:20: identifier not found: c (undefined)"}},
+		{"for ([k, v] in [[1, 2]]) { puts(v) }", []string{"1:7: loop variable k is declared but never used (unused)"}},
+		{"match (1) { 1 => 0, n if n > 0 => n }", []string{"1:1: match may have no arm for some values. add `_ =>` as the last arm (non-exhaustive)"}},
+		{"match (1) { 1 | _ => 0 }", []string{}},
+		{"match ([1]) { [a] => a, b => a + b }; b", []string{"1:30: identifier not found: a (undefined)", "1:39: identifier not found: b (undefined)"}},
+		{"let n = 1; match (2) { n => n }; puts(n)", []string{}},
+		{"match (1) { n => fn() { g(n) } }; let g = fn(x) { x }", []string{}},
+		{"match (true) { true => 1, false => 0 }", []string{}},
+		{"match ([1]) { [x, ...xs] => x, [] => 0, other => other }", []string{"1:22: pattern variable xs is declared but never used (unused)"}},
+		{"match (1) { Integer(n) | String(n) => n, Number(m) => m, _ => y }", []string{"1:42: unknown type Number in pattern (undefined)", "1:63: identifier not found: y (undefined)"}},
+		{"len(1, 2)", []string{"1:1: wrong number of arguments to len. got=2, want=1 (arity)"}},
+		{"env(1, 2)", []string{"1:1: wrong number of arguments to env. got=2, want=0 to 1 (arity)"}},
+		{"puts()", []string{}},
+		{`puts({"a": 1, "b": 2, "a": 3, 1: 1, true: 1, 1: 2})`, []string{
+			"1:23: duplicate key \"a\" in hash literal (duplicate-key)",
+			"1:46: duplicate key 1 in hash literal (duplicate-key)",
+		}},
+		// 関数本体は後から呼ばれるので後で定義された名前を参照できる
+		{"let f = fn() { g() }; let g = fn() { f() }; f()", []string{}},
+		{"let iter = fn(n) { if (n > 0) { iter(n - 1) } }; iter(3)", []string{}},
+		// blockはscopeを作らない
+		{"if (true) { let y = 1 }; puts(y)", []string{}},
+		{"for (x in [1]) { puts(x) }", []string{}},
+		{"for (x in [1]) { puts(1) }", []string{"1:6: loop variable x is declared but never used (unused)"}},
+		{"for (_x in xs) { 1 }", []string{"1:12: identifier not found: xs (undefined)"}},
+		{"let g = fn(n) { yield n; yield m }; g(1)", []string{"1:32: identifier not found: m (undefined)"}},
+		{"let x = ", []string{"1:9: no prefix parse function for EOF (syntax)"}},
+	}
+
+	for _, tt := range tests {
+		diagnostics := LintSource(tt.input, DefaultConfig())
+		got := []string{}
+		for _, d := range diagnostics {
+			got = append(got, d.String())
+		}
+		if len(got) != len(tt.expected) {
+			t.Errorf("%q: wrong diagnostics. want=%q, got=%q", tt.input, tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("%q: wrong diagnostics. want=%q, got=%q", tt.input, tt.expected, got)
+				break
+			}
+		}
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.Rules = map[string]bool{UNUSED: true}
+
+	diagnostics := LintSource("let x = y", config)
+	if len(diagnostics) != 1 || diagnostics[0].Rule != UNUSED {
+		t.Errorf("only unused rule should be reported. got=%v", diagnostics)
+	}
+}
+
+func TestLintExamples(t *testing.T) {
+	paths, err := filepath.Glob("../../examples/*.choco")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range LintSource(string(src), DefaultConfig()) {
+			t.Errorf("%s:%s", path, d)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package linter
+
+import "choco/src/ast"
+
+// bindingの種類
+const (
+	GLOBAL    = "global"
+	LET       = "let"
+	PARAMETER = "parameter"
+	LOOP      = "loop variable"
+	PATTERN   = "pattern variable" // matchの腕で束縛する名前
+)
+
+type binding struct {
+	name  string
+	pos   ast.Position
+	kind  string
+	value ast.Expression // letの右辺. 引数の場合はnil
+	used  bool
+}
+
+// evaluatorと同じく関数呼び出しとmatchの腕ごとに1つのscopeを作る. blockはscopeを作らない
+type scope struct {
+	parent   *scope
+	bindings map[string]*binding
+	declared []*binding             // 宣言順. 再定義されたものも含む
+	pending  []*ast.FunctionLiteral // scopeの走査後に本体を走査する関数
+	arms     []*scope               // matchの腕のscope. 親のscopeと一緒に閉じる
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, bindings: map[string]*binding{}}
+}
+
+func (s *scope) declare(name string, pos ast.Position, kind string, value ast.Expression) *binding {
+	b := &binding{name: name, pos: pos, kind: kind, value: value}
+	s.bindings[name] = b
+	s.declared = append(s.declared, b)
+	return b
+}
+
+func (s *scope) lookup(name string) (*binding, bool) {
+	for current := s; current != nil; current = current.parent {
+		if b, ok := current.bindings[name]; ok {
+			return b, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,379 @@
+package linter
+
+import (
+	"choco/src/ast"
+	"choco/src/evaluator"
+	"choco/src/object"
+	"fmt"
+	"strings"
+)
+
+// 関数本体(またはprogram)を走査する.
+// 関数は定義より後で呼ばれるので, 本体はscope内の文を全て見てから走査する
+func (l *linter) walkFunction(s *scope, stmts []ast.Statement) {
+	l.walkStatements(s, stmts)
+	l.closeScope(s)
+}
+
+// 後回しにした関数の本体とmatchの腕のscopeを走査してから, 使われなかった名前を報告する
+func (l *linter) closeScope(s *scope) {
+	for i := 0; i < len(s.pending); i++ {
+		fn := s.pending[i]
+		child := newScope(s)
+		// 既定値はそれより前の引数を参照できる
+		for i, param := range fn.Parameters {
+			l.walkExpression(child, fn.Default(i))
+			l.declarePattern(child, param, PARAMETER)
+		}
+		if fn.Rest != nil {
+			l.declare(child, fn.Rest, PARAMETER, nil)
+		}
+		l.walkFunction(child, fn.Body.Statements)
+	}
+	for _, arm := range s.arms {
+		l.closeScope(arm)
+	}
+
+	for _, b := range s.declared {
+		if b.kind == GLOBAL || b.used || strings.HasPrefix(b.name, "_") {
+			continue
+		}
+		l.report(UNUSED, b.pos, "%s %s is declared but never used", b.kind, b.name)
+	}
+}
+
+func (l *linter) declare(s *scope, ident *ast.Identifier, kind string, value ast.Expression) {
+	pos := ast.Start(ident)
+	if _, ok := evaluator.LookupBuiltin(ident.Value); ok {
+		l.report(SHADOWED_BUILTIN, pos, "%s %s shadows the builtin function", kind, ident.Value)
+	}
+	s.declare(ident.Value, pos, kind, value)
+}
+
+// patternの中の既定値を走査しながら名前を宣言する. 既定値は左にある名前を参照できる
+func (l *linter) declarePattern(s *scope, pattern ast.Pattern, kind string) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		l.declare(s, pattern, kind, nil)
+	case *ast.ArrayPattern:
+		for i, elem := range pattern.Elements {
+			l.walkExpression(s, pattern.Defaults[i])
+			l.declarePattern(s, elem, kind)
+		}
+		if pattern.Rest != nil {
+			l.declare(s, pattern.Rest, kind, nil)
+		}
+	case *ast.HashPattern:
+		for i, value := range pattern.Values {
+			l.walkExpression(s, pattern.Defaults[i])
+			l.declarePattern(s, value, kind)
+		}
+		if pattern.Rest != nil {
+			l.declare(s, pattern.Rest, kind, nil)
+		}
+	case *ast.TypePattern:
+		if !evaluator.IsTypePattern(pattern.Type) {
+			l.report(UNDEFINED, ast.Start(pattern), "unknown type %s in pattern", pattern.Type)
+		}
+		if pattern.Pattern != nil {
+			l.declarePattern(s, pattern.Pattern, kind)
+		}
+	case *ast.AlternativePattern:
+		// どの選択肢も同じ名前を束縛するので, 最初の選択肢の名前だけを宣言する
+		l.declarePattern(s, pattern.Alternatives[0], kind)
+	}
+}
+
+func (l *linter) walkStatements(s *scope, stmts []ast.Statement) {
+	terminated, reported := false, false
+	for _, stmt := range stmts {
+		// 同じblockでは最初の1文だけ報告する
+		if terminated && !reported {
+			l.report(UNREACHABLE, ast.Start(stmt), "unreachable code after return")
+			reported = true
+		}
+		l.walkStatement(s, stmt)
+		if terminates(stmt) {
+			terminated = true
+		}
+	}
+}
+
+func (l *linter) walkStatement(s *scope, stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		l.walkExpression(s, stmt.Value)
+		if stmt.Pattern != nil {
+			l.declarePattern(s, stmt.Pattern, LET)
+		} else {
+			l.declare(s, stmt.Name, LET, stmt.Value)
+		}
+	case *ast.ReturnStatement:
+		l.walkExpression(s, stmt.ReturnValue)
+	case *ast.YieldStatement:
+		l.walkExpression(s, stmt.Value)
+	case *ast.ExpressionStatement:
+		l.walkExpression(s, stmt.Expression)
+	}
+}
+
+func (l *linter) walkExpression(s *scope, expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		if b, ok := s.lookup(expr.Value); ok {
+			b.used = true
+			return
+		}
+		if _, ok := evaluator.LookupBuiltin(expr.Value); ok {
+			return
+		}
+		l.report(UNDEFINED, ast.Start(expr), "identifier not found: %s", expr.Value)
+	case *ast.PrefixExpression:
+		l.walkExpression(s, expr.Right)
+	case *ast.InfixExpression:
+		l.walkExpression(s, expr.Left)
+		l.walkExpression(s, expr.Right)
+	case *ast.IfExpression:
+		l.walkExpression(s, expr.Condition)
+		l.walkStatements(s, expr.Consequence.Statements)
+		if expr.Alternative != nil {
+			l.walkStatements(s, expr.Alternative.Statements)
+		}
+	case *ast.ForExpression:
+		l.walkExpression(s, expr.Iterable)
+		l.declarePattern(s, expr.Variable, LOOP)
+		l.walkStatements(s, expr.Body.Statements)
+	case *ast.MatchExpression:
+		l.walkExpression(s, expr.Subject)
+		for _, arm := range expr.Arms {
+			child := newScope(s)
+			s.arms = append(s.arms, child)
+			l.declarePattern(child, arm.Pattern, PATTERN)
+			l.walkExpression(child, arm.Guard)
+			l.walkExpression(child, arm.Value)
+		}
+		if !exhaustive(expr) {
+			l.report(NON_EXHAUSTIVE, ast.Start(expr), "match may have no arm for some values. add `_ =>` as the last arm")
+		}
+	case *ast.FunctionLiteral:
+		s.pending = append(s.pending, expr)
+	case *ast.CallExpression:
+		l.walkExpression(s, expr.Function)
+		for _, arg := range expr.Arguments {
+			l.walkExpression(s, arg)
+		}
+		l.checkCall(s, expr)
+	case *ast.SpreadExpression:
+		l.walkExpression(s, expr.Value)
+	case *ast.NamedArgument:
+		l.walkExpression(s, expr.Value)
+	case *ast.ArrayLiteral:
+		for _, elem := range expr.Elements {
+			l.walkExpression(s, elem)
+		}
+	case *ast.SetLiteral:
+		for _, elem := range expr.Elements {
+			l.walkExpression(s, elem)
+		}
+	case *ast.RangeExpression:
+		l.walkExpression(s, expr.Start)
+		l.walkExpression(s, expr.End)
+	case *ast.IndexExpression:
+		l.walkExpression(s, expr.Left)
+		l.walkExpression(s, expr.Index)
+	case *ast.SliceExpression:
+		l.walkExpression(s, expr.Left)
+		l.walkExpression(s, expr.Start)
+		l.walkExpression(s, expr.End)
+		l.walkExpression(s, expr.Step)
+	case *ast.HashLiteral:
+		seen := map[string]bool{}
+		for _, key := range expr.Keys {
+			l.walkExpression(s, key)
+			l.walkExpression(s, expr.Pairs[key])
+
+			if literal, ok := literalKey(key); ok {
+				if seen[literal] {
+					name := key.String()
+					if str, ok := key.(*ast.StringLiteral); ok {
+						name = fmt.Sprintf("%q", str.Value)
+					}
+					l.report(DUPLICATE_KEY, ast.Start(key), "duplicate key %s in hash literal", name)
+				}
+				seen[literal] = true
+			}
+		}
+	}
+}
+
+// 呼び出し先が静的にわかる場合に呼び出せるかと引数の数を検査する
+func (l *linter) checkCall(s *scope, call *ast.CallExpression) {
+	pos := ast.Start(call)
+	callee := call.Function
+	name := callee.String()
+
+	if ident, ok := callee.(*ast.Identifier); ok {
+		b, ok := s.lookup(ident.Value)
+		if !ok {
+			if builtin, ok := evaluator.LookupBuiltin(ident.Value); ok {
+				l.checkArguments(pos, name, call, builtin.Params, builtin.MinArgs, builtin.MaxArgs)
+			}
+			return
+		}
+		if b.value == nil {
+			return
+		}
+		callee = b.value
+	}
+
+	if fn, ok := callee.(*ast.FunctionLiteral); ok {
+		params := []string{}
+		for _, param := range fn.Parameters {
+			// patternの引数は名前で渡せない
+			if ident, ok := param.(*ast.Identifier); ok {
+				params = append(params, ident.Value)
+			} else {
+				params = append(params, "")
+			}
+		}
+		min, max := fn.Arity()
+		l.checkArguments(pos, name, call, params, min, max)
+		return
+	}
+	if objectType, ok := literalType(callee); ok {
+		l.report(NOT_CALLABLE, pos, "not a function: %s", objectType)
+	}
+}
+
+// 名前付きの引数の名前と引数の数を検査する. spreadがあると引数の数はわからない
+func (l *linter) checkArguments(pos ast.Position, name string, call *ast.CallExpression, params []string, min int, max int) {
+	spread, positional := false, 0
+	given := map[string]bool{}
+	for _, arg := range call.Arguments {
+		switch arg := arg.(type) {
+		case *ast.SpreadExpression:
+			spread = true
+		case *ast.NamedArgument:
+			idx := indexOf(params, arg.Name.Value)
+			switch {
+			case idx < 0:
+				l.report(NAMED_ARGUMENT, ast.Start(arg), "%s has no parameter named %s", name, arg.Name.Value)
+			case given[arg.Name.Value] || (idx < positional && !spread):
+				l.report(NAMED_ARGUMENT, ast.Start(arg), "argument %s to %s is given more than once", arg.Name.Value, name)
+			}
+			given[arg.Name.Value] = true
+		default:
+			positional++
+		}
+	}
+	if !spread {
+		l.checkArity(pos, name, len(call.Arguments), min, max)
+	}
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *linter) checkArity(pos ast.Position, name string, got int, min int, max int) {
+	if got >= min && (max < 0 || got <= max) {
+		return
+	}
+	l.report(ARITY, pos, "wrong number of arguments to %s. got=%d, want=%s", name, got, evaluator.DescribeArity(min, max))
+}
+
+// 関数ではないliteralの型
+func literalType(expr ast.Expression) (object.ObjectType, bool) {
+	switch expr.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ, true
+	case *ast.StringLiteral:
+		return object.STRING_OBJ, true
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ, true
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ, true
+	case *ast.HashLiteral:
+		return object.HASH_OBJ, true
+	case *ast.SetLiteral:
+		return object.SET_OBJ, true
+	case *ast.RangeExpression:
+		return object.RANGE_OBJ, true
+	default:
+		return "", false
+	}
+}
+
+// hash keyとして同じ値になるliteralは同じ文字列を返す
+func literalKey(expr ast.Expression) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return fmt.Sprintf("%s:%d", object.INTEGER_OBJ, expr.Value), true
+	case *ast.StringLiteral:
+		return fmt.Sprintf("%s:%s", object.STRING_OBJ, expr.Value), true
+	case *ast.Boolean:
+		return fmt.Sprintf("%s:%t", object.BOOLEAN_OBJ, expr.Value), true
+	default:
+		return "", false
+	}
+}
+
+// guardの無い腕が全ての値に合うか. trueとfalseの両方の腕がある場合も全ての値に合う
+func exhaustive(expr *ast.MatchExpression) bool {
+	booleans := map[bool]bool{}
+	var covers func(pattern ast.Pattern) bool
+	covers = func(pattern ast.Pattern) bool {
+		switch pattern := pattern.(type) {
+		case *ast.Identifier, *ast.WildcardPattern:
+			return true
+		case *ast.LiteralPattern:
+			if boolean, ok := pattern.Value.(*ast.Boolean); ok {
+				booleans[boolean.Value] = true
+			}
+		case *ast.AlternativePattern:
+			all := false
+			for _, alternative := range pattern.Alternatives {
+				all = covers(alternative) || all
+			}
+			return all
+		}
+		return false
+	}
+
+	for _, arm := range expr.Arms {
+		if arm.Guard == nil && covers(arm.Pattern) {
+			return true
+		}
+	}
+	return booleans[true] && booleans[false]
+}
+
+// 文の後に続く文が実行されないか
+func terminates(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement:
+		ifExpr, ok := stmt.Expression.(*ast.IfExpression)
+		if !ok || ifExpr.Alternative == nil {
+			return false
+		}
+		return blockTerminates(ifExpr.Consequence) && blockTerminates(ifExpr.Alternative)
+	default:
+		return false
+	}
+}
+
+func blockTerminates(block *ast.BlockStatement) bool {
+	for _, stmt := range block.Statements {
+		if terminates(stmt) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package lsp
+
+import (
+	"choco/src/ast"
+	"choco/src/lexer"
+	"choco/src/linter"
+	"choco/src/parser"
+	"strings"
+	"unicode/utf8"
+)
+
+// 開かれているファイル. 変更のたびに作り直す
+type document struct {
+	uri         string
+	text        string
+	lines       []string
+	index       *index
+	diagnostics []linter.Diagnostic
+}
+
+func newDocument(uri, text string) *document {
+	program := parser.New(lexer.New(text)).ParseProgram()
+	return &document{
+		uri:         uri,
+		text:        text,
+		lines:       strings.Split(text, "\n"),
+		index:       buildIndex(program),
+		diagnostics: linter.LintSource(text, linter.DefaultConfig()),
+	}
+}
+
+// ast.Position(1-based, byte単位)をLSPのPosition(0-based, UTF-16単位)にする
+func (d *document) toLSP(pos ast.Position) Position {
+	line := pos.Line - 1
+	if line < 0 {
+		return Position{}
+	}
+	if line >= len(d.lines) {
+		last := len(d.lines) - 1
+		return Position{Line: last, Character: utf16Len(d.lines[last])}
+	}
+	text := d.lines[line]
+	column := pos.Column - 1
+	if column > len(text) {
+		column = len(text)
+	}
+	return Position{Line: line, Character: utf16Len(text[:column])}
+}
+
+func (d *document) fromLSP(pos Position) ast.Position {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return ast.Position{Line: pos.Line + 1, Column: 1}
+	}
+	text := d.lines[pos.Line]
+	units := 0
+	for i, r := range text {
+		if units >= pos.Character {
+			return ast.Position{Line: pos.Line + 1, Column: i + 1}
+		}
+		units += utf16RuneLen(r)
+	}
+	return ast.Position{Line: pos.Line + 1, Column: len(text) + 1}
+}
+
+func (d *document) identRange(ident *ast.Identifier) Range {
+	start := ast.Start(ident)
+	end := ast.Position{Line: start.Line, Column: start.Column + len(ident.Value)}
+	return Range{Start: d.toLSP(start), End: d.toLSP(end)}
+}
+
+func (d *document) location(ident *ast.Identifier) Location {
+	return Location{URI: d.uri, Range: d.identRange(ident)}
+}
+
+// 文書全体の範囲
+func (d *document) fullRange() Range {
+	last := len(d.lines) - 1
+	return Range{End: Position{Line: last, Character: utf16Len(d.lines[last])}}
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+func astPosition(d linter.Diagnostic) ast.Position {
+	return ast.Position{Line: d.Line, Column: d.Column}
+}
@@ -0,0 +1,314 @@
+package lsp
+
+import (
+	"choco/src/ast"
+	"choco/src/object"
+	"strings"
+)
+
+// bindingの種類
+const (
+	LET       = "let"
+	PARAMETER = "parameter"
+	LOOP      = "loop variable"
+	PATTERN   = "pattern variable"
+)
+
+// letまたは引数で定義された名前
+type symbol struct {
+	name     string
+	kind     string
+	ident    *ast.Identifier
+	let      *ast.LetStatement    // kindがLETの場合
+	function *ast.FunctionLiteral // kindがPARAMETERの場合は属する関数
+	loop     *ast.ForExpression   // kindがLOOPの場合
+	match    *ast.MatchExpression // kindがPATTERNの場合
+	arm      *ast.MatchArm        // kindがPATTERNの場合
+	refs     []*ast.Identifier    // 定義以外の参照
+	children []*symbol            // 値が関数の場合, その本体で定義されたlet
+}
+
+// 識別子の出現. symbolがnilのものはbuiltinか未定義
+type occurrence struct {
+	ident  *ast.Identifier
+	symbol *symbol
+}
+
+type index struct {
+	symbols     []*symbol // programの直下で定義されたlet
+	all         []*symbol
+	occurrences []occurrence
+}
+
+// evaluatorと同じく関数とmatchの腕ごとにscopeを作る. blockはscopeを作らない
+type scope struct {
+	parent  *scope
+	owner   *symbol // 関数を値に持つlet
+	names   map[string]*symbol
+	pending []pendingFunction
+	arms    []*scope // matchの腕のscope. 中の関数は親のscopeの関数と一緒に走査する
+}
+
+type pendingFunction struct {
+	function *ast.FunctionLiteral
+	owner    *symbol
+}
+
+func buildIndex(program *ast.Program) *index {
+	idx := &index{}
+	idx.walkFunction(&scope{names: map[string]*symbol{}}, program.Statements)
+	return idx
+}
+
+// 関数本体は定義より後で呼ばれるので, scope内の文を全て見てから走査する
+func (idx *index) walkFunction(s *scope, stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		idx.walkStatement(s, stmt)
+	}
+	idx.walkPending(s)
+}
+
+func (idx *index) walkPending(s *scope) {
+	for i := 0; i < len(s.pending); i++ {
+		fn := s.pending[i]
+		child := &scope{parent: s, owner: fn.owner, names: map[string]*symbol{}}
+		// 既定値はそれより前の引数を参照できる
+		for i, param := range fn.function.Parameters {
+			idx.walkExpression(child, fn.function.Default(i))
+			idx.definePattern(child, param, func(ident *ast.Identifier) *symbol {
+				return &symbol{name: ident.Value, kind: PARAMETER, ident: ident, function: fn.function}
+			})
+		}
+		if rest := fn.function.Rest; rest != nil {
+			idx.define(child, &symbol{name: rest.Value, kind: PARAMETER, ident: rest, function: fn.function})
+		}
+		if fn.function.Body != nil {
+			idx.walkFunction(child, fn.function.Body.Statements)
+		}
+	}
+	for _, arm := range s.arms {
+		idx.walkPending(arm)
+	}
+}
+
+func (idx *index) define(s *scope, sym *symbol) {
+	s.names[sym.name] = sym
+	idx.all = append(idx.all, sym)
+	idx.occurrences = append(idx.occurrences, occurrence{ident: sym.ident, symbol: sym})
+
+	if sym.kind != LET {
+		return
+	}
+	if s.owner != nil {
+		s.owner.children = append(s.owner.children, sym)
+	} else if s.parent == nil {
+		idx.symbols = append(idx.symbols, sym)
+	}
+}
+
+// patternの中の既定値を走査しながら, 束縛する名前ごとにnewSymbolで作ったsymbolを定義する
+func (idx *index) definePattern(s *scope, pattern ast.Pattern, newSymbol func(*ast.Identifier) *symbol) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		idx.define(s, newSymbol(pattern))
+	case *ast.ArrayPattern:
+		for i, elem := range pattern.Elements {
+			idx.walkExpression(s, pattern.Defaults[i])
+			idx.definePattern(s, elem, newSymbol)
+		}
+		if pattern.Rest != nil {
+			idx.define(s, newSymbol(pattern.Rest))
+		}
+	case *ast.HashPattern:
+		for i, value := range pattern.Values {
+			idx.walkExpression(s, pattern.Defaults[i])
+			idx.definePattern(s, value, newSymbol)
+		}
+		if pattern.Rest != nil {
+			idx.define(s, newSymbol(pattern.Rest))
+		}
+	case *ast.TypePattern:
+		if pattern.Pattern != nil {
+			idx.definePattern(s, pattern.Pattern, newSymbol)
+		}
+	case *ast.AlternativePattern:
+		// 他の選択肢の同じ名前は最初の選択肢で定義した名前の参照にする
+		idx.definePattern(s, pattern.Alternatives[0], newSymbol)
+		for _, alternative := range pattern.Alternatives[1:] {
+			for _, ident := range ast.Names(alternative) {
+				idx.walkExpression(s, ident)
+			}
+		}
+	}
+}
+
+func (idx *index) walkStatement(s *scope, stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if stmt.Pattern != nil {
+			idx.walkExpression(s, stmt.Value)
+			idx.definePattern(s, stmt.Pattern, func(ident *ast.Identifier) *symbol {
+				return &symbol{name: ident.Value, kind: LET, ident: ident, let: stmt}
+			})
+			return
+		}
+		if stmt.Name == nil {
+			return
+		}
+		sym := &symbol{name: stmt.Name.Value, kind: LET, ident: stmt.Name, let: stmt}
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			s.pending = append(s.pending, pendingFunction{function: fn, owner: sym})
+		} else {
+			idx.walkExpression(s, stmt.Value)
+		}
+		idx.define(s, sym)
+	case *ast.ReturnStatement:
+		idx.walkExpression(s, stmt.ReturnValue)
+	case *ast.YieldStatement:
+		idx.walkExpression(s, stmt.Value)
+	case *ast.ExpressionStatement:
+		idx.walkExpression(s, stmt.Expression)
+	}
+}
+
+func (idx *index) walkStatements(s *scope, block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		idx.walkStatement(s, stmt)
+	}
+}
+
+func (idx *index) walkExpression(s *scope, expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		sym := s.lookup(expr.Value)
+		if sym != nil {
+			sym.refs = append(sym.refs, expr)
+		}
+		idx.occurrences = append(idx.occurrences, occurrence{ident: expr, symbol: sym})
+	case *ast.PrefixExpression:
+		idx.walkExpression(s, expr.Right)
+	case *ast.InfixExpression:
+		idx.walkExpression(s, expr.Left)
+		idx.walkExpression(s, expr.Right)
+	case *ast.IfExpression:
+		idx.walkExpression(s, expr.Condition)
+		idx.walkStatements(s, expr.Consequence)
+		idx.walkStatements(s, expr.Alternative)
+	case *ast.ForExpression:
+		idx.walkExpression(s, expr.Iterable)
+		idx.definePattern(s, expr.Variable, func(ident *ast.Identifier) *symbol {
+			return &symbol{name: ident.Value, kind: LOOP, ident: ident, loop: expr}
+		})
+		idx.walkStatements(s, expr.Body)
+	case *ast.MatchExpression:
+		idx.walkExpression(s, expr.Subject)
+		for _, arm := range expr.Arms {
+			arm := arm
+			child := &scope{parent: s, owner: s.owner, names: map[string]*symbol{}}
+			s.arms = append(s.arms, child)
+			idx.definePattern(child, arm.Pattern, func(ident *ast.Identifier) *symbol {
+				return &symbol{name: ident.Value, kind: PATTERN, ident: ident, match: expr, arm: arm}
+			})
+			idx.walkExpression(child, arm.Guard)
+			idx.walkExpression(child, arm.Value)
+		}
+	case *ast.FunctionLiteral:
+		s.pending = append(s.pending, pendingFunction{function: expr, owner: s.owner})
+	case *ast.CallExpression:
+		idx.walkExpression(s, expr.Function)
+		for _, arg := range expr.Arguments {
+			idx.walkExpression(s, arg)
+		}
+	case *ast.SpreadExpression:
+		idx.walkExpression(s, expr.Value)
+	case *ast.NamedArgument:
+		idx.walkExpression(s, expr.Value)
+	case *ast.ArrayLiteral:
+		for _, elem := range expr.Elements {
+			idx.walkExpression(s, elem)
+		}
+	case *ast.SetLiteral:
+		for _, elem := range expr.Elements {
+			idx.walkExpression(s, elem)
+		}
+	case *ast.RangeExpression:
+		idx.walkExpression(s, expr.Start)
+		idx.walkExpression(s, expr.End)
+	case *ast.IndexExpression:
+		idx.walkExpression(s, expr.Left)
+		idx.walkExpression(s, expr.Index)
+	case *ast.SliceExpression:
+		idx.walkExpression(s, expr.Left)
+		idx.walkExpression(s, expr.Start)
+		idx.walkExpression(s, expr.End)
+		idx.walkExpression(s, expr.Step)
+	case *ast.HashLiteral:
+		for _, key := range expr.Keys {
+			idx.walkExpression(s, key)
+			idx.walkExpression(s, expr.Pairs[key])
+		}
+	}
+}
+
+func (s *scope) lookup(name string) *symbol {
+	for current := s; current != nil; current = current.parent {
+		if sym, ok := current.names[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// posにある識別子. 識別子の直後にcursorがある場合も含む
+func (idx *index) occurrenceAt(pos ast.Position) (occurrence, bool) {
+	for _, o := range idx.occurrences {
+		start := ast.Start(o.ident)
+		if start.Line == pos.Line && start.Column <= pos.Column && pos.Column <= start.Column+len(o.ident.Value) {
+			return o, true
+		}
+	}
+	return occurrence{}, false
+}
+
+// 関数の引数部分. 例: fn(a, b = 1, ...rest)
+func signature(fn *ast.FunctionLiteral) string {
+	params := []string{}
+	for i, p := range fn.Parameters {
+		if value := fn.Default(i); value != nil {
+			params = append(params, p.String()+" = "+value.String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if fn.Rest != nil {
+		params = append(params, "..."+fn.Rest.Value)
+	}
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+// letの値の種類. literal以外は実行しないとわからない
+func valueKind(expr ast.Expression) string {
+	switch expr.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ
+	case *ast.StringLiteral:
+		return object.STRING_OBJ
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ
+	case *ast.HashLiteral:
+		return object.HASH_OBJ
+	case *ast.SetLiteral:
+		return object.SET_OBJ
+	case *ast.RangeExpression:
+		return object.RANGE_OBJ
+	case *ast.FunctionLiteral:
+		return "FUNCTION"
+	default:
+		return "expression"
+	}
+}
@@ -0,0 +1,48 @@
+package lsp
+
+import "encoding/json"
+
+// JSON-RPCのerror code
+const (
+	PARSE_ERROR      = -32700
+	INVALID_PARAMS   = -32602
+	METHOD_NOT_FOUND = -32601
+	INVALID_REQUEST  = -32600
+)
+
+// request or notification. notificationはIDを持たない
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// 成功した応答. resultがnullでもresultを持つ
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+}
+
+// 失敗した応答. resultは持たない
+type errorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *responseError  `json:"error"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
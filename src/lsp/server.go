@@ -0,0 +1,411 @@
+package lsp
+
+import (
+	"bufio"
+	"choco/src/ast"
+	"choco/src/evaluator"
+	"choco/src/formatter"
+	"choco/src/linter"
+	"choco/src/rpc"
+	"choco/src/token"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Server is a language server speaking LSP over a pair of streams
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	log       io.Writer
+	documents map[string]*document
+	shutdown  bool
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		"initialize":                  (*Server).initialize,
+		"initialized":                 (*Server).ignore,
+		"shutdown":                    (*Server).handleShutdown,
+		"textDocument/didOpen":        (*Server).didOpen,
+		"textDocument/didChange":      (*Server).didChange,
+		"textDocument/didClose":       (*Server).didClose,
+		"textDocument/didSave":        (*Server).ignore,
+		"textDocument/definition":     (*Server).definition,
+		"textDocument/references":     (*Server).references,
+		"textDocument/hover":          (*Server).hover,
+		"textDocument/documentSymbol": (*Server).documentSymbol,
+		"textDocument/completion":     (*Server).completion,
+		"textDocument/formatting":     (*Server).formatting,
+	}
+}
+
+// NewServer reads requests from in and writes responses to out. log receives errors.
+func NewServer(in io.Reader, out io.Writer, log io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		log:       log,
+		documents: map[string]*document{},
+	}
+}
+
+// Serve handles messages until exit notification or end of input and returns exit status
+func (s *Server) Serve() int {
+	for {
+		body, err := rpc.ReadMessage(s.in)
+		if err == io.EOF {
+			return 1
+		}
+		if err != nil {
+			fmt.Fprintf(s.log, "[ERROR] %s\n", err)
+			return 1
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.reply(json.RawMessage("null"), nil, &responseError{Code: PARSE_ERROR, Message: err.Error()})
+			continue
+		}
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		s.handle(msg)
+	}
+}
+
+func (s *Server) handle(msg message) {
+	isRequest := len(msg.ID) != 0
+	h, ok := handlers[msg.Method]
+	if !ok {
+		// 未対応のnotificationは無視する
+		if isRequest {
+			s.reply(msg.ID, nil, &responseError{Code: METHOD_NOT_FOUND, Message: "method not found: " + msg.Method})
+		}
+		return
+	}
+	if s.shutdown && isRequest {
+		s.reply(msg.ID, nil, &responseError{Code: INVALID_REQUEST, Message: "server is shut down"})
+		return
+	}
+
+	result, err := h(s, msg.Params)
+	if !isRequest {
+		if err != nil {
+			fmt.Fprintf(s.log, "[ERROR] %s: %s\n", msg.Method, err)
+		}
+		return
+	}
+	if err != nil {
+		rerr, ok := err.(*responseError)
+		if !ok {
+			rerr = &responseError{Code: INVALID_PARAMS, Message: err.Error()}
+		}
+		s.reply(msg.ID, nil, rerr)
+		return
+	}
+	s.reply(msg.ID, result, nil)
+}
+
+func (s *Server) reply(id json.RawMessage, result interface{}, err *responseError) {
+	if err != nil {
+		s.write(errorResponse{JSONRPC: "2.0", ID: id, Error: err})
+		return
+	}
+	s.write(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) write(v interface{}) {
+	if err := rpc.WriteMessage(s.out, v); err != nil {
+		fmt.Fprintf(s.log, "[ERROR] %s\n", err)
+	}
+}
+
+func (s *Server) ignore(params json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var result InitializeResult
+	result.ServerInfo.Name = "choco"
+	result.Capabilities = ServerCapabilities{
+		TextDocumentSync:           SYNC_FULL,
+		DefinitionProvider:         true,
+		ReferencesProvider:         true,
+		HoverProvider:              true,
+		DocumentSymbolProvider:     true,
+		CompletionProvider:         struct{}{},
+		DocumentFormattingProvider: true,
+	}
+	return result, nil
+}
+
+func (s *Server) handleShutdown(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p DidOpenTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.update(p.TextDocument.URI, p.TextDocument.Text)
+	return nil, nil
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p DidChangeTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	// SYNC_FULLなので最後の変更が全文になる
+	s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	return nil, nil
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p DidCloseTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.documents, p.TextDocument.URI)
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	return nil, nil
+}
+
+func (s *Server) update(uri, text string) {
+	doc := newDocument(uri, text)
+	s.documents[uri] = doc
+
+	diagnostics := []Diagnostic{}
+	for _, d := range doc.diagnostics {
+		severity := SEVERITY_WARNING
+		if d.Rule == linter.SYNTAX {
+			severity = SEVERITY_ERROR
+		}
+		start := doc.toLSP(astPosition(d))
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{Start: start, End: start},
+			Severity: severity,
+			Code:     d.Rule,
+			Source:   "choco",
+			Message:  d.Message,
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, fmt.Errorf("document is not open: %s", uri)
+	}
+	return doc, nil
+}
+
+// cursor位置の識別子を探す
+func (s *Server) occurrenceAt(p TextDocumentPositionParams) (*document, occurrence, bool, error) {
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, occurrence{}, false, err
+	}
+	o, ok := doc.index.occurrenceAt(doc.fromLSP(p.Position))
+	return doc, o, ok, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, o, ok, err := s.occurrenceAt(p)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || o.symbol == nil {
+		return nil, nil
+	}
+	return []Location{doc.location(o.symbol.ident)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	var p ReferenceParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, o, ok, err := s.occurrenceAt(p.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+	locations := []Location{}
+	if !ok || o.symbol == nil {
+		return locations, nil
+	}
+	if p.Context.IncludeDeclaration {
+		locations = append(locations, doc.location(o.symbol.ident))
+	}
+	for _, ref := range o.symbol.refs {
+		locations = append(locations, doc.location(ref))
+	}
+	return locations, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, o, ok, err := s.occurrenceAt(p)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	var text string
+	if o.symbol != nil {
+		text = describe(o.symbol)
+	} else if builtin, ok := evaluator.LookupBuiltin(o.ident.Value); ok {
+		text = fmt.Sprintf("builtin %s: arguments %s", o.ident.Value, evaluator.DescribeArity(builtin.MinArgs, builtin.MaxArgs))
+	} else {
+		return nil, nil
+	}
+
+	r := doc.identRange(o.ident)
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```choco\n" + text + "\n```"},
+		Range:    &r,
+	}, nil
+}
+
+// hoverに表示する説明
+func describe(sym *symbol) string {
+	switch sym.kind {
+	case LET:
+		if sym.let.Pattern != nil {
+			return fmt.Sprintf("let %s from %s", sym.name, sym.let.Pattern.String())
+		}
+		if fn, ok := sym.let.Value.(*ast.FunctionLiteral); ok {
+			return fmt.Sprintf("let %s = %s", sym.name, signature(fn))
+		}
+		return fmt.Sprintf("let %s: %s", sym.name, valueKind(sym.let.Value))
+	case LOOP:
+		return fmt.Sprintf("for %s in %s", sym.name, sym.loop.Iterable.String())
+	case PATTERN:
+		return fmt.Sprintf("match %s { %s }", sym.match.Subject.String(), sym.arm.Pattern.String())
+	default:
+		return fmt.Sprintf("parameter %s of %s", sym.name, signature(sym.function))
+	}
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p DocumentSymbolParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return documentSymbols(doc, doc.index.symbols), nil
+}
+
+func documentSymbols(doc *document, symbols []*symbol) []DocumentSymbol {
+	result := []DocumentSymbol{}
+	for _, sym := range symbols {
+		selection := doc.identRange(sym.ident)
+		ds := DocumentSymbol{
+			Name:           sym.name,
+			Kind:           SYMBOL_VARIABLE,
+			Range:          Range{Start: doc.toLSP(ast.Start(sym.let)), End: selection.End},
+			SelectionRange: selection,
+		}
+		if fn, ok := sym.let.Value.(*ast.FunctionLiteral); ok && sym.let.Pattern == nil {
+			ds.Kind = SYMBOL_FUNCTION
+			ds.Detail = signature(fn)
+			if fn.Body != nil {
+				ds.Range.End = doc.toLSP(ast.Position{Line: fn.Body.EndToken.Line, Column: fn.Body.EndToken.Column + 1})
+			}
+		}
+		if len(sym.children) != 0 {
+			ds.Children = documentSymbols(doc, sym.children)
+		}
+		result = append(result, ds)
+	}
+	return result
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	add := func(item CompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	// 候補の絞り込みはclientに任せる
+	symbols := append([]*symbol{}, doc.index.all...)
+	sort.SliceStable(symbols, func(i, j int) bool { return symbols[i].name < symbols[j].name })
+	for _, sym := range symbols {
+		item := CompletionItem{Label: sym.name, Kind: COMPLETION_VARIABLE, Detail: describe(sym)}
+		if sym.kind == LET {
+			if _, ok := sym.let.Value.(*ast.FunctionLiteral); ok && sym.let.Pattern == nil {
+				item.Kind = COMPLETION_FUNCTION
+			}
+		}
+		add(item)
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		add(CompletionItem{Label: name, Kind: COMPLETION_FUNCTION, Detail: "builtin"})
+	}
+	for _, keyword := range token.Keywords() {
+		add(CompletionItem{Label: keyword, Kind: COMPLETION_KEYWORD})
+	}
+	return items, nil
+}
+
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p DocumentFormattingParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := formatter.Format(doc.text)
+	if err != nil || formatted == doc.text {
+		// 構文エラーがある場合はdiagnosticsで報告済みなので何もしない
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: doc.fullRange(), NewText: formatted}}, nil
+}
@@ -0,0 +1,446 @@
+package lsp
+
+import (
+	"bufio"
+	"choco/src/rpc"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.choco"
+
+// JSON-RPCでServerと通信するclient
+type client struct {
+	t             *testing.T
+	in            io.WriteCloser
+	out           *bufio.Reader
+	nextID        int
+	notifications []notification
+	done          chan int
+}
+
+type rawResponse struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, in: clientOut, out: bufio.NewReader(clientIn), done: make(chan int)}
+
+	server := NewServer(serverIn, serverOut, ioutil.Discard)
+	go func() {
+		status := server.Serve()
+		serverOut.Close()
+		c.done <- status
+	}()
+	return c
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := rpc.WriteMessage(c.in, notification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// requestを送り, 応答のresultをresultに読み込む
+func (c *client) call(method string, params interface{}, result interface{}) *responseError {
+	c.t.Helper()
+	c.nextID++
+	id := c.nextID
+	req := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+	if err := rpc.WriteMessage(c.in, req); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		msg := c.read()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, notification{Method: msg.Method, Params: msg.Params})
+			continue
+		}
+		if *msg.ID != id {
+			c.t.Fatalf("unexpected response id. want=%d, got=%d", id, *msg.ID)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("couldn't decode result of %s: %s", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) read() rawResponse {
+	c.t.Helper()
+	body, err := rpc.ReadMessage(c.out)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var msg rawResponse
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// 次のpublishDiagnosticsを読む
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	msg := c.read()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected publishDiagnostics. got=%+v", msg)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+func (c *client) open(text string) PublishDiagnosticsParams {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testURI, Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+func (c *client) close() int {
+	c.t.Helper()
+	if err := c.call("shutdown", nil, nil); err != nil {
+		c.t.Fatal(err)
+	}
+	c.notify("exit", nil)
+	c.in.Close()
+	return <-c.done
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func rng(line, start, end int) Range {
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+const testSource = `let add = fn(a, b) {
+    let sum = a + b
+    sum
+}
+let x = add(1, 2)
+puts(x, add(x, 3))
+`
+
+func TestInitializeAndShutdown(t *testing.T) {
+	c := newClient(t)
+	var result InitializeResult
+	if err := c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Capabilities.DefinitionProvider || result.Capabilities.TextDocumentSync != SYNC_FULL {
+		t.Errorf("wrong capabilities: %+v", result.Capabilities)
+	}
+
+	if err := c.call("unknown/method", nil, nil); err == nil || err.Code != METHOD_NOT_FOUND {
+		t.Errorf("expected method not found. got=%v", err)
+	}
+
+	if status := c.close(); status != 0 {
+		t.Errorf("wrong exit status. got=%d", status)
+	}
+}
+
+func TestResponseShape(t *testing.T) {
+	c := newClient(t)
+	// 応答のtop levelのfield
+	fields := func(method string) map[string]json.RawMessage {
+		t.Helper()
+		c.nextID++
+		req := map[string]interface{}{"jsonrpc": "2.0", "id": c.nextID, "method": method}
+		if err := rpc.WriteMessage(c.in, req); err != nil {
+			t.Fatal(err)
+		}
+		body, err := rpc.ReadMessage(c.out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	m := fields("unknown/method")
+	if _, ok := m["result"]; ok || m["error"] == nil {
+		t.Errorf("error response should have only error. got=%v", m)
+	}
+	m = fields("shutdown")
+	if _, ok := m["error"]; ok || string(m["result"]) != "null" {
+		t.Errorf("null result should be kept without error. got=%v", m)
+	}
+
+	c.notify("exit", nil)
+	c.in.Close()
+	if status := <-c.done; status != 0 {
+		t.Errorf("wrong exit status. got=%d", status)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+
+	params := c.open("let x = 1;\nputs(y)")
+	expected := []Diagnostic{
+		{Range: rng(0, 4, 4), Severity: SEVERITY_WARNING, Code: "unused", Source: "choco", Message: "let x is declared but never used"},
+		{Range: rng(1, 5, 5), Severity: SEVERITY_WARNING, Code: "undefined", Source: "choco", Message: "identifier not found: y"},
+	}
+	if params.URI != testURI || !reflect.DeepEqual(params.Diagnostics, expected) {
+		t.Errorf("wrong diagnostics. got=%+v", params)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: testURI},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let = 1"}},
+	})
+	params = c.diagnostics()
+	if len(params.Diagnostics) == 0 || params.Diagnostics[0].Severity != SEVERITY_ERROR ||
+		!strings.Contains(params.Diagnostics[0].Message, `expected next token is: "IDENT"`) {
+		t.Errorf("wrong syntax diagnostics. got=%+v", params)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}})
+	if params := c.diagnostics(); len(params.Diagnostics) != 0 {
+		t.Errorf("diagnostics should be cleared on close. got=%+v", params)
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(testSource)
+
+	tests := []struct {
+		position TextDocumentPositionParams
+		expected []Location
+	}{
+		// add(1, 2)のadd
+		{at(4, 9), []Location{{URI: testURI, Range: rng(0, 4, 7)}}},
+		// 関数本体のa
+		{at(1, 14), []Location{{URI: testURI, Range: rng(0, 13, 14)}}},
+		// sum(識別子の直後)
+		{at(2, 7), []Location{{URI: testURI, Range: rng(1, 8, 11)}}},
+		// builtin
+		{at(5, 1), nil},
+	}
+	for _, tt := range tests {
+		var got []Location
+		if err := c.call("textDocument/definition", tt.position, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("definition at %+v: want=%+v, got=%+v", tt.position.Position, tt.expected, got)
+		}
+	}
+
+	var refs []Location
+	params := ReferenceParams{TextDocumentPositionParams: at(0, 5)}
+	params.Context.IncludeDeclaration = true
+	if err := c.call("textDocument/references", params, &refs); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Location{
+		{URI: testURI, Range: rng(0, 4, 7)},
+		{URI: testURI, Range: rng(4, 8, 11)},
+		{URI: testURI, Range: rng(5, 8, 11)},
+	}
+	if !reflect.DeepEqual(refs, expected) {
+		t.Errorf("wrong references. got=%+v", refs)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(testSource)
+
+	tests := []struct {
+		position TextDocumentPositionParams
+		expected string
+	}{
+		{at(5, 9), "let add = fn(a, b)"},
+		{at(5, 5), "let x: expression"},
+		{at(1, 18), "parameter b of fn(a, b)"},
+		{at(5, 0), "builtin puts: arguments 0 or more"},
+	}
+	for _, tt := range tests {
+		var got Hover
+		if err := c.call("textDocument/hover", tt.position, &got); err != nil {
+			t.Fatal(err)
+		}
+		expected := "```choco\n" + tt.expected + "\n```"
+		if got.Contents.Value != expected {
+			t.Errorf("hover at %+v: want=%q, got=%q", tt.position.Position, expected, got.Contents.Value)
+		}
+	}
+
+	c.open("let n = 10")
+	var got Hover
+	c.call("textDocument/hover", at(0, 4), &got)
+	if got.Contents.Value != "```choco\nlet n: INTEGER\n```" {
+		t.Errorf("wrong hover for literal. got=%q", got.Contents.Value)
+	}
+
+	c.open("for (x in 0..<3) { puts(x) }")
+	c.call("textDocument/hover", at(0, 24), &got)
+	if got.Contents.Value != "```choco\nfor x in (0..<3)\n```" {
+		t.Errorf("wrong hover for loop variable. got=%q", got.Contents.Value)
+	}
+
+	c.open("let f = fn(a, b = 1, ...r) { a }")
+	c.call("textDocument/hover", at(0, 4), &got)
+	if got.Contents.Value != "```choco\nlet f = fn(a, b = 1, ...r)\n```" {
+		t.Errorf("wrong hover for default and rest parameters. got=%q", got.Contents.Value)
+	}
+
+	c.open("let {name, age: a} = user")
+	c.call("textDocument/hover", at(0, 16), &got)
+	if got.Contents.Value != "```choco\nlet a from {name,age:a}\n```" {
+		t.Errorf("wrong hover for destructured let. got=%q", got.Contents.Value)
+	}
+
+	c.open("match (xs) { [Integer(n)] | [String(n)] => n }")
+	c.call("textDocument/hover", at(0, 43), &got)
+	if got.Contents.Value != "```choco\nmatch xs { [Integer(n)]|[String(n)] }\n```" {
+		t.Errorf("wrong hover for pattern variable. got=%q", got.Contents.Value)
+	}
+
+	// 選択肢の同じ名前は最初の選択肢の名前の参照になる
+	var refs []Location
+	params := ReferenceParams{TextDocumentPositionParams: at(0, 43)}
+	params.Context.IncludeDeclaration = true
+	if err := c.call("textDocument/references", params, &refs); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Location{
+		{URI: testURI, Range: rng(0, 22, 23)},
+		{URI: testURI, Range: rng(0, 36, 37)},
+		{URI: testURI, Range: rng(0, 43, 44)},
+	}
+	if !reflect.DeepEqual(refs, expected) {
+		t.Errorf("wrong references to pattern variable. got=%+v", refs)
+	}
+
+	// 腕の名前は腕の外では定義されていない
+	c.open("match (1) { n => n }; n")
+	var defs []Location
+	if err := c.call("textDocument/definition", at(0, 22), &defs); err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 0 {
+		t.Errorf("pattern variable is visible after match. got=%+v", defs)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(testSource)
+
+	var got []DocumentSymbol
+	if err := c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := []DocumentSymbol{
+		{
+			Name: "add", Detail: "fn(a, b)", Kind: SYMBOL_FUNCTION,
+			Range:          Range{Start: Position{0, 0}, End: Position{3, 1}},
+			SelectionRange: rng(0, 4, 7),
+			Children: []DocumentSymbol{
+				{Name: "sum", Kind: SYMBOL_VARIABLE, Range: rng(1, 4, 11), SelectionRange: rng(1, 8, 11)},
+			},
+		},
+		{Name: "x", Kind: SYMBOL_VARIABLE, Range: rng(4, 0, 5), SelectionRange: rng(4, 4, 5)},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong symbols.\nwant=%+v\ngot =%+v", expected, got)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(testSource)
+
+	var items []CompletionItem
+	if err := c.call("textDocument/completion", at(5, 0), &items); err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]int{}
+	for _, item := range items {
+		kinds[item.Label] = item.Kind
+	}
+	expected := map[string]int{
+		"add": COMPLETION_FUNCTION,
+		"x":   COMPLETION_VARIABLE,
+		"sum": COMPLETION_VARIABLE,
+		"len": COMPLETION_FUNCTION,
+		"let": COMPLETION_KEYWORD,
+	}
+	for label, kind := range expected {
+		if kinds[label] != kind {
+			t.Errorf("completion %s: want kind %d, got %d", label, kind, kinds[label])
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open("let x=1+2\nputs( x )")
+
+	params := DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: testURI}}
+	var edits []TextEdit
+	if err := c.call("textDocument/formatting", params, &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{{Range: rng(1, 0, 9), NewText: "let x = 1 + 2\nputs(x)\n"}}
+	expected[0].Range.Start.Line = 0
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("wrong edits. got=%+v", edits)
+	}
+
+	c.open("let = 1")
+	if err := c.call("textDocument/formatting", params, &edits); err != nil || len(edits) != 0 {
+		t.Errorf("syntax error should produce no edits. got=%+v, err=%v", edits, err)
+	}
+}
+
+func TestPositionConversion(t *testing.T) {
+	doc := newDocument(testURI, "let s = \"日本🍫\"; s")
+	// 最後のsはバイト単位では23列目, UTF-16単位では16文字目(0-based)
+	pos := doc.fromLSP(Position{Line: 0, Character: 16})
+	if pos.Column != 23 {
+		t.Errorf("fromLSP: wrong column. got=%d", pos.Column)
+	}
+	if got := doc.toLSP(pos); got != (Position{Line: 0, Character: 16}) {
+		t.Errorf("toLSP: wrong position. got=%+v", got)
+	}
+}
@@ -1,8 +1,12 @@
 package object
 
+import "sort"
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	// generatorの呼び出しで作られた環境の場合にyieldの送り先
+	yield func(Object) bool
 }
 
 func NewEnvironment() *Environment {
@@ -28,3 +32,45 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env.outer = outer
 	return env
 }
+
+// SetYield makes yield statements evaluated in this environment call fn.
+// fn returns false when the generator is closed and the body should stop.
+func (e *Environment) SetYield(fn func(Object) bool) {
+	e.yield = fn
+}
+
+// Yield returns the function set by SetYield. outer environments are not searched,
+// since yield belongs to the innermost function.
+func (e *Environment) Yield() (func(Object) bool, bool) {
+	return e.yield, e.yield != nil
+}
+
+// Outer returns the enclosing environment, or nil for the global one
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Names returns the sorted names bound in this environment, excluding outer ones
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Each calls fn for every binding visible from this environment, inner environments first.
+// names shadowed by an inner binding are visited only once.
+func (e *Environment) Each(fn func(name string, val Object)) {
+	visited := map[string]bool{}
+	for env := e; env != nil; env = env.outer {
+		for _, name := range env.Names() {
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			fn(name, env.store[name])
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package object
+
+import "strings"
+
+// 比較中の組. 循環した構造を辿り直さないために使う
+type visit struct {
+	a, b Object
+}
+
+// Equal reports whether a and b are structurally equal.
+// arrays, hashes and sets are compared by their contents, hashes and sets regardless of their order,
+// and functions and builtins by identity. it terminates on cyclic values.
+func Equal(a, b Object) bool {
+	return equal(a, b, map[visit]bool{})
+}
+
+func equal(a, b Object, visiting map[visit]bool) bool {
+	if a == b {
+		return true
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *Null:
+		return true
+	case *Array:
+		b := b.(*Array)
+		if len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		// 比較中の組に戻ってきたら, 他の部分が等しければ等しいとみなす
+		if visiting[visit{a, b}] {
+			return true
+		}
+		visiting[visit{a, b}] = true
+		defer delete(visiting, visit{a, b})
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i], visiting) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b := b.(*Hash)
+		if a.Len() != b.Len() {
+			return false
+		}
+		if visiting[visit{a, b}] {
+			return true
+		}
+		visiting[visit{a, b}] = true
+		defer delete(visiting, visit{a, b})
+		for _, pair := range a.pairs {
+			other, ok := b.Get(pair.Key)
+			if !ok || !equal(pair.Value, other.Value, visiting) {
+				return false
+			}
+		}
+		return true
+	case *Range:
+		// 同じ整数の列になるかで比べる
+		b := b.(*Range)
+		n := a.Len()
+		return n == b.Len() && (n == 0 || a.Start == b.Start)
+	case *Set:
+		b := b.(*Set)
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, elem := range a.Elements() {
+			if !b.Has(elem) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// Compare returns -1, 0 or 1 as a is less than, equal to or greater than b.
+// integers, strings and booleans(false < true) are compared with values of the same type,
+// and arrays lexicographically by their elements. ok is false when a and b are not comparable.
+func Compare(a, b Object) (result int, ok bool) {
+	return compare(a, b, map[visit]bool{})
+}
+
+func compare(a, b Object, visiting map[visit]bool) (int, bool) {
+	if a.Type() != b.Type() {
+		return 0, false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return compareInt(a.Value, b.(*Integer).Value), true
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value), true
+	case *Boolean:
+		return compareBool(a.Value, b.(*Boolean).Value), true
+	case *Array:
+		b := b.(*Array)
+		if visiting[visit{a, b}] {
+			return 0, true
+		}
+		visiting[visit{a, b}] = true
+		defer delete(visiting, visit{a, b})
+		for i := 0; i < len(a.Elements) && i < len(b.Elements); i++ {
+			result, ok := compare(a.Elements[i], b.Elements[i], visiting)
+			if !ok || result != 0 {
+				return result, ok
+			}
+		}
+		// 先頭が等しい場合は短い方が小さい
+		return compareInt(int64(len(a.Elements)), int64(len(b.Elements))), true
+	default:
+		return 0, false
+	}
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case b:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,32 @@
+package object
+
+import "testing"
+
+func TestEqualCyclic(t *testing.T) {
+	// 自身を含む配列. 言語からはまだ作れないので直接組み立てる
+	a := &Array{Elements: []Object{&Integer{Value: 1}}}
+	a.Elements = append(a.Elements, a)
+	b := &Array{Elements: []Object{&Integer{Value: 1}}}
+	b.Elements = append(b.Elements, b)
+	c := &Array{Elements: []Object{&Integer{Value: 2}}}
+	c.Elements = append(c.Elements, c)
+
+	if !Equal(a, b) {
+		t.Errorf("cyclic arrays with the same contents should be equal")
+	}
+	if Equal(a, c) {
+		t.Errorf("cyclic arrays with different contents should not be equal")
+	}
+	if result, ok := Compare(a, c); !ok || result != -1 {
+		t.Errorf("wrong comparison of cyclic arrays. result=%d, ok=%t", result, ok)
+	}
+
+	h := &Hash{}
+	key := &String{Value: "self"}
+	h.Set(key, h)
+	g := &Hash{}
+	g.Set(key, g)
+	if !Equal(h, g) {
+		t.Errorf("cyclic hashes with the same contents should be equal")
+	}
+}
@@ -0,0 +1,61 @@
+package object
+
+// Closer is implemented by iterators which hold resources until they are exhausted
+type Closer interface {
+	// Close releases the resources. the iterator produces no more values after it.
+	Close()
+}
+
+// Generator is the iterator returned by calling a function which contains yield
+type Generator struct {
+	// 関数の名前. 無名関数の場合は空
+	Name  string
+	next  func() (Object, bool)
+	close func()
+	done  bool
+	// nextの途中. 本体から自分自身を進めると待ち合ってdeadlockになるので見つける
+	running bool
+}
+
+// NewGenerator returns a generator which produces values by next.
+// close is called once, when the generator is exhausted or closed.
+func NewGenerator(name string, next func() (Object, bool), close func()) *Generator {
+	return &Generator{Name: name, next: next, close: close}
+}
+
+func (o *Generator) Next() (Object, bool) {
+	if o.done {
+		return nil, false
+	}
+	if o.running {
+		return &Error{Message: "generator already running"}, true
+	}
+	o.running = true
+	value, ok := func() (Object, bool) {
+		defer func() { o.running = false }()
+		return o.next()
+	}()
+	if _, isError := value.(*Error); !ok || isError {
+		o.Close()
+	}
+	return value, ok
+}
+
+// Close stops the generator. it is safe to call more than once.
+// closing a running generator from its own body does nothing, since its caller is still waiting for it.
+func (o *Generator) Close() {
+	if o.done || o.running {
+		return
+	}
+	o.done = true
+	o.close()
+}
+
+func (o *Generator) Iterate() Iterator { return o }
+func (o *Generator) Type() ObjectType  { return GENERATOR_OBJ }
+func (o *Generator) Inspect() string {
+	if o.Name == "" {
+		return "generator"
+	}
+	return "generator " + o.Name
+}
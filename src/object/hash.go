@@ -0,0 +1,220 @@
+package object
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// HashKeyOf returns the hash key of obj. integers, strings and booleans are hashable,
+// and so are arrays, hashes and sets whose contents are all hashable. ok is false otherwise.
+// different values can share a hash key, so keys must also be compared with Equal.
+func HashKeyOf(obj Object) (key HashKey, ok bool) {
+	return hashKeyOf(obj, map[Object]bool{})
+}
+
+func hashKeyOf(obj Object, visiting map[Object]bool) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case Hashable:
+		return obj.HashKey(), true
+	case *Array:
+		// 循環した値は内容から求められない
+		if visiting[obj] {
+			return HashKey{}, false
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		h := fnv.New64a()
+		for _, elem := range obj.Elements {
+			key, ok := hashKeyOf(elem, visiting)
+			if !ok {
+				return HashKey{}, false
+			}
+			writeHashKey(h, key)
+		}
+		return HashKey{Type: obj.Type(), Value: h.Sum64()}, true
+	case *Hash:
+		if visiting[obj] {
+			return HashKey{}, false
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		// 順序に依らないように各pairのhashの和をとる
+		var sum uint64
+		for _, pair := range obj.pairs {
+			key, ok := hashKeyOf(pair.Key, visiting)
+			if !ok {
+				return HashKey{}, false
+			}
+			value, ok := hashKeyOf(pair.Value, visiting)
+			if !ok {
+				return HashKey{}, false
+			}
+			h := fnv.New64a()
+			writeHashKey(h, key)
+			writeHashKey(h, value)
+			sum += h.Sum64()
+		}
+		return HashKey{Type: obj.Type(), Value: sum}, true
+	case *Set:
+		if visiting[obj] {
+			return HashKey{}, false
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		var sum uint64
+		for _, pair := range obj.elements.pairs {
+			key, ok := hashKeyOf(pair.Key, visiting)
+			if !ok {
+				return HashKey{}, false
+			}
+			h := fnv.New64a()
+			writeHashKey(h, key)
+			sum += h.Sum64()
+		}
+		return HashKey{Type: obj.Type(), Value: sum}, true
+	default:
+		return HashKey{}, false
+	}
+}
+
+func writeHashKey(h interface{ Write([]byte) (int, error) }, key HashKey) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], key.Value)
+	h.Write([]byte(key.Type))
+	h.Write(buf[:])
+}
+
+// Hash is an ordered map which keeps pairs in the order their keys were first set.
+// the zero value is an empty hash.
+type Hash struct {
+	pairs []HashPair
+	// HashKeyからpairsの添字を引く. 衝突した場合は複数になる
+	index map[HashKey][]int
+}
+
+// Len returns the number of pairs
+func (o *Hash) Len() int { return len(o.pairs) }
+
+// 見つからない場合は-1
+func (o *Hash) find(hashed HashKey, key Object) int {
+	for _, i := range o.index[hashed] {
+		if Equal(o.pairs[i].Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the pair of key. ok is false when key is not found or not hashable.
+func (o *Hash) Get(key Object) (pair HashPair, ok bool) {
+	hashed, ok := HashKeyOf(key)
+	if !ok {
+		return HashPair{}, false
+	}
+	i := o.find(hashed, key)
+	if i < 0 {
+		return HashPair{}, false
+	}
+	return o.pairs[i], true
+}
+
+// Set associates key with value. a pair which already exists is replaced in place.
+// it returns false without changing the hash when key is not hashable.
+func (o *Hash) Set(key, value Object) bool {
+	hashed, ok := HashKeyOf(key)
+	if !ok {
+		return false
+	}
+	if i := o.find(hashed, key); i >= 0 {
+		o.pairs[i] = HashPair{Key: key, Value: value}
+		return true
+	}
+	if o.index == nil {
+		o.index = map[HashKey][]int{}
+	}
+	o.index[hashed] = append(o.index[hashed], len(o.pairs))
+	o.pairs = append(o.pairs, HashPair{Key: key, Value: value})
+	return true
+}
+
+// Delete removes the pair of key and reports whether it existed.
+// it takes linear time to keep the order of the rest.
+func (o *Hash) Delete(key Object) bool {
+	hashed, ok := HashKeyOf(key)
+	if !ok {
+		return false
+	}
+	i := o.find(hashed, key)
+	if i < 0 {
+		return false
+	}
+
+	bucket := []int{}
+	for _, j := range o.index[hashed] {
+		if j != i {
+			bucket = append(bucket, j)
+		}
+	}
+	if len(bucket) == 0 {
+		delete(o.index, hashed)
+	} else {
+		o.index[hashed] = bucket
+	}
+
+	o.pairs = append(o.pairs[:i], o.pairs[i+1:]...)
+	for _, bucket := range o.index {
+		for k, j := range bucket {
+			if j > i {
+				bucket[k] = j - 1
+			}
+		}
+	}
+	return true
+}
+
+// Copy returns a shallow copy of the hash
+func (o *Hash) Copy() *Hash {
+	hash := &Hash{
+		pairs: make([]HashPair, len(o.pairs)),
+		index: make(map[HashKey][]int, len(o.index)),
+	}
+	copy(hash.pairs, o.pairs)
+	for key, bucket := range o.index {
+		hash.index[key] = append([]int{}, bucket...)
+	}
+	return hash
+}
+
+// Pairs returns the pairs in insertion order. the slice must not be modified.
+func (o *Hash) Pairs() []HashPair { return o.pairs }
+
+func (o *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range o.pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ","))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+func (o *Hash) Type() ObjectType { return HASH_OBJ }
@@ -0,0 +1,61 @@
+package object
+
+import "testing"
+
+func TestHashOrder(t *testing.T) {
+	hash := &Hash{}
+	for _, key := range []string{"c", "a", "d", "b"} {
+		k := &String{Value: key}
+		hash.Set(k, &Integer{Value: 1})
+	}
+	a := &String{Value: "a"}
+	hash.Set(a, &Integer{Value: 2})
+	if !hash.Delete(&String{Value: "d"}) {
+		t.Fatalf("d is not deleted")
+	}
+	if hash.Delete(&String{Value: "d"}) {
+		t.Fatalf("d is deleted twice")
+	}
+
+	if hash.Inspect() != "{c: 1,a: 2,b: 1}" || hash.Len() != 3 {
+		t.Fatalf("wrong hash. got=%s", hash.Inspect())
+	}
+	// 削除で詰めた後も引ける
+	pair, ok := hash.Get(&String{Value: "b"})
+	if !ok || pair.Key.Inspect() != "b" {
+		t.Errorf("b is not found after deleting d. got=%v", pair)
+	}
+}
+
+// 常に同じHashKeyを返すキー. 同一のポインタだけがEqualになる
+type collidingKey struct{ name string }
+
+func (o *collidingKey) Inspect() string  { return o.name }
+func (o *collidingKey) Type() ObjectType { return "COLLIDING" }
+func (o *collidingKey) HashKey() HashKey { return HashKey{Type: o.Type(), Value: 42} }
+
+func TestHashKeyCollision(t *testing.T) {
+	a, b, c := &collidingKey{"a"}, &collidingKey{"b"}, &collidingKey{"c"}
+	hash := &Hash{}
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+	hash.Set(c, &Integer{Value: 3})
+	hash.Set(b, &Integer{Value: 4})
+
+	if hash.Inspect() != "{a: 1,b: 4,c: 3}" {
+		t.Fatalf("colliding keys overwrote each other. got=%s", hash.Inspect())
+	}
+	hash.Delete(a)
+	for _, tt := range []struct {
+		key      Object
+		expected string
+	}{{b, "4"}, {c, "3"}} {
+		pair, ok := hash.Get(tt.key)
+		if !ok || pair.Value.Inspect() != tt.expected {
+			t.Errorf("wrong value of %s after deleting a. got=%v", tt.key.Inspect(), pair.Value)
+		}
+	}
+	if _, ok := hash.Get(a); ok {
+		t.Errorf("deleted key a is found")
+	}
+}
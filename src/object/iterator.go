@@ -0,0 +1,80 @@
+package object
+
+// Iterator produces values one by one. an *Error value ends the iteration
+// and should be propagated by the consumer.
+type Iterator interface {
+	Object
+	// Next returns the next value. ok is false when the iterator is exhausted.
+	Next() (value Object, ok bool)
+}
+
+// Iterable is implemented by objects which can be iterated over:
+// arrays, strings(by characters), hashes(by keys), sets, ranges and iterators themselves.
+type Iterable interface {
+	Object
+	// Iterate returns a new iterator, except that an iterator returns itself
+	Iterate() Iterator
+}
+
+// FuncIterator is an Iterator which calls a function for each value
+type FuncIterator struct {
+	next func() (Object, bool)
+	done bool
+}
+
+// NewIterator returns an iterator which produces values by next
+func NewIterator(next func() (Object, bool)) *FuncIterator {
+	return &FuncIterator{next: next}
+}
+
+func (o *FuncIterator) Next() (Object, bool) {
+	// 終わった後は呼ばない
+	if o.done {
+		return nil, false
+	}
+	value, ok := o.next()
+	if _, isError := value.(*Error); !ok || isError {
+		o.done = true
+	}
+	return value, ok
+}
+
+func (o *FuncIterator) Iterate() Iterator { return o }
+func (o *FuncIterator) Inspect() string   { return "iterator" }
+func (o *FuncIterator) Type() ObjectType  { return ITERATOR_OBJ }
+
+// 要素の列を順に返す
+func sliceIterator(elements []Object) *FuncIterator {
+	i := 0
+	return NewIterator(func() (Object, bool) {
+		if i >= len(elements) {
+			return nil, false
+		}
+		i++
+		return elements[i-1], true
+	})
+}
+
+func (o *Array) Iterate() Iterator { return sliceIterator(o.Elements) }
+
+func (o *String) Iterate() Iterator {
+	runes := []rune(o.Value)
+	i := 0
+	return NewIterator(func() (Object, bool) {
+		if i >= len(runes) {
+			return nil, false
+		}
+		i++
+		return &String{Value: string(runes[i-1])}, true
+	})
+}
+
+func (o *Hash) Iterate() Iterator {
+	keys := make([]Object, len(o.pairs))
+	for i, pair := range o.pairs {
+		keys[i] = pair.Key
+	}
+	return sliceIterator(keys)
+}
+
+func (o *Set) Iterate() Iterator { return sliceIterator(o.Elements()) }
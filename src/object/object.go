@@ -22,6 +22,10 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	SET_OBJ          = "SET"
+	RANGE_OBJ        = "RANGE"
+	ITERATOR_OBJ     = "ITERATOR"
+	GENERATOR_OBJ    = "GENERATOR"
 )
 
 type Object interface {
@@ -93,17 +97,30 @@ func (o *Error) Inspect() string { return "ERROR: " + o.Message }
 func (o *Error) Type() ObjectType { return ERROR_OBJ }
 
 type Function struct {
-	Parameters []*ast.Identifier
+	Parameters []ast.Pattern
+	Defaults   []ast.Expression // Parametersと同じ順の既定値. 既定値の無い引数はnil
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	// 最初に束縛されたletの名前. 無名関数の場合は空. profilerなどが表示に使う
+	Name      string
+	Position  ast.Position // fnの位置
+	Generator bool         // 呼び出すと本体を実行せずgeneratorを返す
 }
 
 func (o *Function) Inspect() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range o.Parameters {
-		params = append(params, p.String())
+	for i, p := range o.Parameters {
+		if i < len(o.Defaults) && o.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+o.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if o.Rest != nil {
+		params = append(params, "..."+o.Rest.String())
 	}
 
 	out.WriteString("fn")
@@ -118,10 +135,30 @@ func (o *Function) Inspect() string {
 
 func (o *Function) Type() ObjectType { return FUNCTION_OBJ }
 
+// Arity returns the range of the number of arguments the function accepts.
+// max is -1 when the function has a rest parameter.
+func (o *Function) Arity() (min int, max int) {
+	for i := range o.Parameters {
+		if i >= len(o.Defaults) || o.Defaults[i] == nil {
+			min = i + 1
+		}
+	}
+	if o.Rest != nil {
+		return min, -1
+	}
+	return min, len(o.Parameters)
+}
+
 type BuiltinFunction func(args ...Object) Object
 
 type Builtin struct {
-	Fn BuiltinFunction
+	Name string
+	Fn   BuiltinFunction
+	// 受け付ける引数の数. linterが呼び出しを検査するのに使う. MaxArgsが負の場合は上限なし
+	MinArgs int
+	MaxArgs int
+	// 名前でも渡せる引数の名前. 位置の順に並べる
+	Params []string
 }
 
 func (o *Builtin) Inspect() string { return "builtin function" }
@@ -147,31 +184,3 @@ func (o *Array) Inspect() string {
 }
 
 func (o *Array) Type() ObjectType { return ARRAY_OBJ }
-
-type HashKey struct {
-	Type  ObjectType
-	Value uint64
-}
-type HashPair struct {
-	Key   Object
-	Value Object
-}
-type Hash struct {
-	Pairs map[HashKey]HashPair
-}
-
-func (o *Hash) Inspect() string {
-	var out bytes.Buffer
-
-	pairs := []string{}
-	for _, pair := range o.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
-	}
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ","))
-	out.WriteString("}")
-
-	return out.String()
-}
-
-func (o *Hash) Type() ObjectType { return HASH_OBJ }
@@ -0,0 +1,68 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// Range is a lazy sequence of integers from Start to End by 1.
+// it is empty when Start is greater than End.
+type Range struct {
+	Start     int64
+	End       int64
+	Inclusive bool // Endを含むか
+}
+
+// Len returns the number of integers in the range.
+// a range with more integers than int64 can hold is counted as math.MaxInt64.
+func (o *Range) Len() int64 {
+	if o.empty() {
+		return 0
+	}
+	// End-Startはint64では溢れるのでuint64で数える
+	n := uint64(o.End) - uint64(o.Start)
+	if o.Inclusive {
+		n++
+	}
+	if n == 0 || n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
+func (o *Range) empty() bool {
+	if o.Inclusive {
+		return o.Start > o.End
+	}
+	return o.Start >= o.End
+}
+
+func (o *Range) Iterate() Iterator {
+	// 最後の値を返したら終わる. 次の値を作る前に止めるのでEndが最大値でも溢れない
+	current, finished := o.Start, o.empty()
+	last := o.End
+	if !o.Inclusive {
+		last--
+	}
+	return NewIterator(func() (Object, bool) {
+		if finished {
+			return nil, false
+		}
+		value := current
+		if value == last {
+			finished = true
+		} else {
+			current++
+		}
+		return &Integer{Value: value}, true
+	})
+}
+
+func (o *Range) Inspect() string {
+	if o.Inclusive {
+		return fmt.Sprintf("%d..%d", o.Start, o.End)
+	}
+	return fmt.Sprintf("%d..<%d", o.Start, o.End)
+}
+
+func (o *Range) Type() ObjectType { return RANGE_OBJ }
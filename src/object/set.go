@@ -0,0 +1,106 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Set is a collection of distinct hashable values in insertion order.
+// the zero value is an empty set.
+type Set struct {
+	// 要素自身をキーと値にしたhash
+	elements Hash
+}
+
+// Len returns the number of elements
+func (o *Set) Len() int { return o.elements.Len() }
+
+// Has reports whether elem is in the set
+func (o *Set) Has(elem Object) bool {
+	_, ok := o.elements.Get(elem)
+	return ok
+}
+
+// Add adds elem to the set. it returns false when elem is not hashable.
+// an element which already exists keeps its position.
+func (o *Set) Add(elem Object) bool {
+	if o.Has(elem) {
+		return true
+	}
+	return o.elements.Set(elem, elem)
+}
+
+// Remove removes elem and reports whether it existed
+func (o *Set) Remove(elem Object) bool {
+	return o.elements.Delete(elem)
+}
+
+// Elements returns the elements in insertion order
+func (o *Set) Elements() []Object {
+	elements := make([]Object, 0, o.Len())
+	for _, pair := range o.elements.Pairs() {
+		elements = append(elements, pair.Key)
+	}
+	return elements
+}
+
+// Copy returns a shallow copy of the set
+func (o *Set) Copy() *Set {
+	return &Set{elements: *o.elements.Copy()}
+}
+
+func (o *Set) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, pair := range o.elements.Pairs() {
+		elements = append(elements, pair.Key.Inspect())
+	}
+	out.WriteString("#{")
+	out.WriteString(strings.Join(elements, ","))
+	out.WriteString("}")
+	return out.String()
+}
+
+func (o *Set) Type() ObjectType { return SET_OBJ }
+
+// Union returns a new set of elements in o or other
+func (o *Set) Union(other *Set) *Set {
+	set := o.Copy()
+	for _, elem := range other.Elements() {
+		set.Add(elem)
+	}
+	return set
+}
+
+// Intersect returns a new set of elements in both o and other
+func (o *Set) Intersect(other *Set) *Set {
+	set := &Set{}
+	for _, elem := range o.Elements() {
+		if other.Has(elem) {
+			set.Add(elem)
+		}
+	}
+	return set
+}
+
+// Difference returns a new set of elements in o but not in other
+func (o *Set) Difference(other *Set) *Set {
+	set := &Set{}
+	for _, elem := range o.Elements() {
+		if !other.Has(elem) {
+			set.Add(elem)
+		}
+	}
+	return set
+}
+
+// IsSubset reports whether every element of o is in other
+func (o *Set) IsSubset(other *Set) bool {
+	for _, elem := range o.Elements() {
+		if !other.Has(elem) {
+			return false
+		}
+	}
+	return true
+}
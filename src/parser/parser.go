@@ -5,7 +5,10 @@ import (
 	"choco/src/lexer"
 	"choco/src/token"
 	"fmt"
+	"io"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -13,6 +16,7 @@ const (
 	LOWEST
 	EQUALS
 	LESSGREATER
+	RANGE
 	SUM
 	PRODUCT
 	PREFIX
@@ -21,18 +25,22 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.GTEQ:     LESSGREATER,
-	token.LTEQ:     LESSGREATER,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.EQ:        EQUALS,
+	token.NOT_EQ:    EQUALS,
+	token.GTEQ:      LESSGREATER,
+	token.LTEQ:      LESSGREATER,
+	token.LT:        LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.DOTDOT:    RANGE,
+	token.DOTDOTLT:  RANGE,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.PIPE:      SUM,
+	token.SLASH:     PRODUCT,
+	token.ASTERISK:  PRODUCT,
+	token.AMPERSAND: PRODUCT,
+	token.LPAREN:    CALL,
+	token.LBRACKET:  INDEX,
 }
 
 type (
@@ -40,14 +48,28 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
+// ParseError is an error with the token where it is found
+type ParseError struct {
+	Token   token.Token
+	Message string
+}
+
 type Parser struct {
 	lexer        *lexer.Lexer
 	currentToken token.Token
 	peekToken    token.Token
 	errors       []string
+	parseErrors  []ParseError
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// 本体を読んでいる途中の関数. yieldは一番内側の関数をgeneratorにする
+	functions []*ast.FunctionLiteral
+
+	// SetTraceOutputで設定される. Parserごとに持つので並行に使える
+	traceOut   io.Writer
+	traceLevel int
 }
 
 func New(lexer *lexer.Lexer) *Parser {
@@ -67,15 +89,22 @@ func New(lexer *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.FOR, p.parseForExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.SET_LBRACE, p.parseSetLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.DOTDOT, p.parseRangeExpression)
+	p.registerInfix(token.DOTDOTLT, p.parseRangeExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
@@ -92,13 +121,24 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// ParseErrors returns the same errors as Errors with their positions
+func (p *Parser) ParseErrors() []ParseError {
+	return p.parseErrors
+}
+
 func (p *Parser) peekError(tt token.TokenType) {
 	msg := fmt.Sprintf("current token is: %q, expected next token is: %q, got %q(%q)", p.currentToken.Literal, tt, p.peekToken.Type, p.peekToken.Literal)
-	p.addError(msg)
+	p.addErrorAt(p.peekToken, msg)
 }
 
 func (p *Parser) addError(msg string) {
-	p.errors = append(p.errors, msg)
+	p.addErrorAt(p.currentToken, msg)
+}
+
+// errorの位置として行番号を添える
+func (p *Parser) addErrorAt(tok token.Token, msg string) {
+	p.errors = append(p.errors, fmt.Sprintf("line %d: %s", tok.Line, msg))
+	p.parseErrors = append(p.parseErrors, ParseError{Token: tok, Message: msg})
 }
 
 func (p *Parser) noPrefixParseFnError(tt token.TokenType) {
@@ -118,6 +158,15 @@ func (p *Parser) registerInfix(tt token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tt] = fn
 }
 
+// PrecedenceOf returns the binding power of an infix or postfix operator token.
+// tokens which are not operators have LOWEST.
+func PrecedenceOf(tt token.TokenType) int {
+	if p, ok := precedences[tt]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
@@ -152,9 +201,18 @@ func (p *Parser) ParseProgram() *ast.Program {
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currentToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		// 失敗時にnilの*ast.LetStatementをinterfaceに入れない
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.YIELD:
+		if stmt := p.parseYieldStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -164,12 +222,18 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	// placed on "let" now
 	stmt := &ast.LetStatement{Token: p.currentToken}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		if stmt.Pattern = p.parsePattern(); stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -201,6 +265,25 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseYieldStatement() *ast.YieldStatement {
+	// placed on "yield" now
+	stmt := &ast.YieldStatement{Token: p.currentToken}
+	if len(p.functions) == 0 {
+		p.addError("yield outside function")
+		return nil
+	}
+	p.functions[len(p.functions)-1].Generator = true
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 // 次のtokenをみて、期待どおりであればそこに進む
 // advanceToken()とverifyを同時に行う
 func (p *Parser) expectPeek(tt token.TokenType) bool {
@@ -222,7 +305,7 @@ func (p *Parser) currentTokenIs(tt token.TokenType) bool {
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	// defer untrace(trace("parseExpressionStatement"))
+	defer p.untrace(p.trace("parseExpressionStatement"))
 	stmt := &ast.ExpressionStatement{Token: p.currentToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -247,6 +330,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	expr.EndToken = p.currentToken
 
 	return expr
 }
@@ -256,7 +340,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 ***************************/
 // parserの中で最重要
 func (p *Parser) parseExpression(precedence int) ast.Expression {
-	// defer untrace(trace("parseExpression"))
+	defer p.untrace(p.trace("parseExpression"))
 	prefix := p.prefixParseFns[p.currentToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.currentToken.Type)
@@ -282,7 +366,7 @@ func (p *Parser) parseIdentifier() ast.Expression {
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	// defer untrace(trace("parseIntegerLiteral"))
+	defer p.untrace(p.trace("parseIntegerLiteral"))
 	expr := &ast.IntegerLiteral{Token: p.currentToken}
 	value, err := strconv.ParseInt(p.currentToken.Literal, 0, 0)
 	if err != nil {
@@ -295,12 +379,12 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
-	// defer untrace(trace("parseBoolean"))
+	defer p.untrace(p.trace("parseBoolean"))
 	return &ast.Boolean{Token: p.currentToken, Value: p.currentTokenIs(token.TRUE)}
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	// defer untrace(trace("parsePrefixExpression"))
+	defer p.untrace(p.trace("parsePrefixExpression"))
 	expr := &ast.PrefixExpression{
 		Token:    p.currentToken,
 		Operator: p.currentToken.Literal,
@@ -314,7 +398,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseInfixExpression(leftExpr ast.Expression) ast.Expression {
-	// defer untrace(trace("parseInfixExpression"))
+	defer p.untrace(p.trace("parseInfixExpression"))
 	expr := &ast.InfixExpression{
 		Token:    p.currentToken,
 		Left:     leftExpr,
@@ -328,8 +412,16 @@ func (p *Parser) parseInfixExpression(leftExpr ast.Expression) ast.Expression {
 	return expr
 }
 
+// rangeは結合しないので右辺は一段強い式だけを取る
+func (p *Parser) parseRangeExpression(start ast.Expression) ast.Expression {
+	expr := &ast.RangeExpression{Token: p.currentToken, Start: start}
+	p.nextToken()
+	expr.End = p.parseExpression(RANGE)
+	return expr
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
-	// defer untrace(trace("parseGroupedExpression"))
+	defer p.untrace(p.trace("parseGroupedExpression"))
 
 	p.nextToken()
 	expr := p.parseExpression(LOWEST)
@@ -384,49 +476,198 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	// fn(p1, p2) の )まで進む
-	node.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(node) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	p.functions = append(p.functions, node)
 	node.Body = p.parseBlockStatement()
+	p.functions = p.functions[:len(p.functions)-1]
 	return node
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expr := &ast.MatchExpression{Token: p.currentToken}
 
-	if p.peekTokenIs(token.RPAREN) {
+	// (
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expr.Subject = p.parseExpression(LOWEST)
+
+	// )
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	// {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	// 腕はカンマで区切る. 最後のカンマは省略できる
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expr.Arms = append(expr.Arms, arm)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		return identifiers
 	}
 
-	p.nextToken()
-	ident := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-	identifiers = append(identifiers, ident)
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	expr.EndToken = p.currentToken
+	return expr
+}
 
-	for p.peekTokenIs(token.COMMA) {
+// pattern if guard => value
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{}
+	if arm.Pattern = p.parseMatchPattern(); arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		identifiers = append(identifiers, ident)
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	p.nextToken()
+	arm.Value = p.parseExpression(LOWEST)
+	return arm
+}
+
+func (p *Parser) parseForExpression() ast.Expression {
+	expr := &ast.ForExpression{Token: p.currentToken}
+
+	// (
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	if expr.Variable = p.parsePattern(); expr.Variable == nil {
+		return nil
 	}
 
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	expr.Iterable = p.parseExpression(LOWEST)
+
+	// )
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
+	// {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expr.Body = p.parseBlockStatement()
 
-	return identifiers
+	return expr
+}
+
+// 既定値のある引数の後には既定値のある引数か...restだけを置ける
+func (p *Parser) parseFunctionParameters(node *ast.FunctionLiteral) bool {
+	node.Parameters = []ast.Pattern{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return true
+	}
+
+	for {
+		p.nextToken()
+		// ...restは最後に置く
+		if p.currentTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			node.Rest = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+			break
+		}
+
+		param := p.parsePattern()
+		if param == nil {
+			return false
+		}
+
+		value := p.parsePatternDefault()
+		if value == nil && len(node.Defaults) > 0 && node.Defaults[len(node.Defaults)-1] != nil {
+			p.addError(fmt.Sprintf("parameter %s without default follows a parameter with default", param.String()))
+			return false
+		}
+		node.Parameters = append(node.Parameters, param)
+		node.Defaults = append(node.Defaults, value)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.currentToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	// 名前付きの引数は位置で渡す引数の後に置く
+	named := false
+	exp.Arguments = p.parseExpressionList(token.RPAREN, func() ast.Expression {
+		start := p.currentToken
+		arg := p.parseArgument()
+		if _, ok := arg.(*ast.NamedArgument); ok {
+			named = true
+		} else if named {
+			p.addErrorAt(start, "positional argument follows named argument")
+		}
+		return arg
+	})
 	return exp
 }
 
-func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+// 引数は...valueとname: valueも取る
+func (p *Parser) parseArgument() ast.Expression {
+	switch {
+	case p.currentTokenIs(token.ELLIPSIS):
+		expr := &ast.SpreadExpression{Token: p.currentToken}
+		p.nextToken()
+		expr.Value = p.parseExpression(LOWEST)
+		return expr
+	case p.currentTokenIs(token.IDENT) && p.peekTokenIs(token.COLON):
+		expr := &ast.NamedArgument{Token: p.currentToken}
+		expr.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		p.nextToken()
+		p.nextToken()
+		expr.Value = p.parseExpression(LOWEST)
+		return expr
+	default:
+		return p.parseExpression(LOWEST)
+	}
+}
+
+func (p *Parser) parseElement() ast.Expression {
+	return p.parseExpression(LOWEST)
+}
+
+// 要素はparseElementで読む
+func (p *Parser) parseExpressionList(end token.TokenType, parseElement func() ast.Expression) []ast.Expression {
 	args := []ast.Expression{}
 
 	if p.peekTokenIs(end) {
@@ -435,12 +676,16 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	args = append(args, parseElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		// 末尾のcommaは許容する
+		if p.peekTokenIs(end) {
+			break
+		}
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		args = append(args, parseElement())
 	}
 
 	if !p.expectPeek(end) {
@@ -456,20 +701,51 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currentToken}
-	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Elements = p.parseExpressionList(token.RBRACKET, p.parseElement)
+	array.EndToken = p.currentToken
 	return array
 }
 
+func (p *Parser) parseSetLiteral() ast.Expression {
+	set := &ast.SetLiteral{Token: p.currentToken}
+	set.Elements = p.parseExpressionList(token.RBRACE, p.parseElement)
+	set.EndToken = p.currentToken
+	return set
+}
+
+// left[index] または left[start:end:step]
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.currentToken, Left: left}
+	tok := p.currentToken
 
-	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+		if p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+		}
+	}
 
+	slice := &ast.SliceExpression{Token: tok, Left: left, Start: index}
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		slice.End = p.parseExpression(LOWEST)
+	}
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			slice.Step = p.parseExpression(LOWEST)
+		}
+	}
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
-	return exp
+	return slice
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
@@ -489,6 +765,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -498,6 +775,210 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.EndToken = p.currentToken
 
 	return hash
 }
+
+/***************************
+* pattern
+***************************/
+// 束縛の左辺. currentTokenはpatternの先頭
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.currentToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayPattern(p.parsePattern)
+	case token.LBRACE:
+		return p.parseHashPattern(p.parsePattern)
+	default:
+		p.addError(fmt.Sprintf("expected pattern, got %q", p.currentToken.Literal))
+		return nil
+	}
+}
+
+// = valueがあれば読む. 無ければnil
+func (p *Parser) parsePatternDefault() ast.Expression {
+	if !p.peekTokenIs(token.ASSIGN) {
+		return nil
+	}
+	p.nextToken()
+	p.nextToken()
+	return p.parseExpression(LOWEST)
+}
+
+// ...restを読む. currentTokenは...
+func (p *Parser) parseRestPattern() *ast.Identifier {
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	return &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+}
+
+// 要素はparseElementで読む
+func (p *Parser) parseArrayPattern(parseElement func() ast.Pattern) ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.currentToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		// ...restは最後に置く
+		if p.currentTokenIs(token.ELLIPSIS) {
+			if pattern.Rest = p.parseRestPattern(); pattern.Rest == nil {
+				return nil
+			}
+			break
+		}
+
+		elem := parseElement()
+		if elem == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, elem)
+		pattern.Defaults = append(pattern.Defaults, p.parsePatternDefault())
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	pattern.EndToken = p.currentToken
+	return pattern
+}
+
+// {name}はkeyと同じ名前に束縛する. {name: n}で別の名前やpatternにする
+func (p *Parser) parseHashPattern(parseValue func() ast.Pattern) ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.currentToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		if p.currentTokenIs(token.ELLIPSIS) {
+			if pattern.Rest = p.parseRestPattern(); pattern.Rest == nil {
+				return nil
+			}
+			break
+		}
+
+		if !p.currentTokenIs(token.IDENT) {
+			p.addError(fmt.Sprintf("expected key name, got %q", p.currentToken.Literal))
+			return nil
+		}
+		key := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		var value ast.Pattern = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if value = parseValue(); value == nil {
+				return nil
+			}
+		}
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+		pattern.Defaults = append(pattern.Defaults, p.parsePatternDefault())
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	pattern.EndToken = p.currentToken
+	return pattern
+}
+
+// matchの腕のpattern. 束縛のpatternに加えてliteral, _, 型, `|`で区切った選択肢を書ける
+func (p *Parser) parseMatchPattern() ast.Pattern {
+	pattern := p.parseMatchAlternative()
+	if pattern == nil || !p.peekTokenIs(token.PIPE) {
+		return pattern
+	}
+
+	alternatives := &ast.AlternativePattern{Alternatives: []ast.Pattern{pattern}}
+	// どの選択肢が合っても同じ名前が使えるようにする.
+	// 名前の違いは構文の誤りではないので, 最初の1つだけ報告して腕の続きを読む
+	names := patternNames(pattern)
+	reported := false
+	for p.peekTokenIs(token.PIPE) {
+		p.nextToken()
+		p.nextToken()
+		start := p.currentToken
+		if pattern = p.parseMatchAlternative(); pattern == nil {
+			return nil
+		}
+		if patternNames(pattern) != names && !reported {
+			p.addErrorAt(start, fmt.Sprintf("alternative %s binds different names from %s", pattern.String(), alternatives.Alternatives[0].String()))
+			reported = true
+		}
+		alternatives.Alternatives = append(alternatives.Alternatives, pattern)
+	}
+	return alternatives
+}
+
+// `|`を含まないmatchのpattern
+func (p *Parser) parseMatchAlternative() ast.Pattern {
+	switch p.currentToken.Type {
+	case token.IDENT:
+		if p.currentToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.currentToken}
+		}
+		if p.peekTokenIs(token.LPAREN) {
+			return p.parseTypePattern()
+		}
+		return &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	case token.INT:
+		return &ast.LiteralPattern{Value: p.parseIntegerLiteral()}
+	case token.STRING:
+		return &ast.LiteralPattern{Value: p.parseStringLiteral()}
+	case token.TRUE, token.FALSE:
+		return &ast.LiteralPattern{Value: p.parseBoolean()}
+	case token.MINUS:
+		// 負の整数だけを書ける
+		expr := &ast.PrefixExpression{Token: p.currentToken, Operator: p.currentToken.Literal}
+		if !p.expectPeek(token.INT) {
+			return nil
+		}
+		expr.Right = p.parseIntegerLiteral()
+		return &ast.LiteralPattern{Value: expr}
+	case token.LBRACKET:
+		return p.parseArrayPattern(p.parseMatchPattern)
+	case token.LBRACE:
+		return p.parseHashPattern(p.parseMatchPattern)
+	default:
+		return p.parsePattern()
+	}
+}
+
+// Integer(n). 型の名前はevaluatorが調べる
+func (p *Parser) parseTypePattern() ast.Pattern {
+	pattern := &ast.TypePattern{Token: p.currentToken, Type: p.currentToken.Literal}
+	p.nextToken()
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		if pattern.Pattern = p.parseMatchPattern(); pattern.Pattern == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	pattern.EndToken = p.currentToken
+	return pattern
+}
+
+// 束縛する名前を並べた文字列. 順番は問わない
+func patternNames(pattern ast.Pattern) string {
+	names := []string{}
+	for _, name := range ast.Names(pattern) {
+		names = append(names, name.Value)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
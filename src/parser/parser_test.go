@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"bytes"
 	"choco/src/ast"
 	"choco/src/lexer"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -257,6 +260,26 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a+b-c",
 			"((a+b)-c)",
 		},
+		{
+			"a|b&c",
+			"(a|(b&c))",
+		},
+		{
+			"0..n+1",
+			"(0..(n+1))",
+		},
+		{
+			"a..<b*2 == c",
+			"((a..<(b*2))==c)",
+		},
+		{
+			"f(1..2)",
+			"f((1..2))",
+		},
+		{
+			"a-b|c*d",
+			"((a-b)|(c*d))",
+		},
 		{
 			"a*b*c",
 			"((a*b)*c)",
@@ -586,6 +609,161 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		min, max int
+	}{
+		{"fn(a, b = 10) {}", "fn(a,b=10)", 1, 2},
+		{"fn(a = 1, b = a * 2) {}", "fn(a=1,b=(a*2))", 0, 2},
+		{"fn(first, ...rest) {}", "fn(first,...rest)", 1, -1},
+		{"fn(a = 1, ...rest) {}", "fn(a=1,...rest)", 0, -1},
+		{"fn(...xs) {}", "fn(...xs)", 0, -1},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if function.String() != tt.expected {
+			t.Errorf("wrong function. want=%s, got=%s", tt.expected, function.String())
+		}
+		if min, max := function.Arity(); min != tt.min || max != tt.max {
+			t.Errorf("%s: wrong arity. want=(%d, %d), got=(%d, %d)", tt.input, tt.min, tt.max, min, max)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a = 1, b) {}", "line 1: parameter b without default follows a parameter with default"},
+		{"fn(...rest, a) {}", "line 1: current token is: \"rest\", expected next token is: \")\", got \",\"(\",\")"},
+		{"fn(1) {}", "line 1: expected pattern, got \"1\""},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%s: wrong errors. want=%q, got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestSpreadArgumentParsing(t *testing.T) {
+	p := New(lexer.New("f(1, ...xs, ...g(a + b))"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if call.String() != "f(1,...xs,...g((a+b)))" {
+		t.Errorf("wrong call. got=%s", call.String())
+	}
+	if _, ok := call.Arguments[1].(*ast.SpreadExpression); !ok {
+		t.Errorf("argument is not ast.SpreadExpression. got=%T", call.Arguments[1])
+	}
+
+	// 引数の外では使えない
+	p = New(lexer.New("[...xs]"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("spread in array literal is parsed")
+	}
+}
+
+func TestNamedArgumentParsing(t *testing.T) {
+	p := New(lexer.New(`render(u, compact: true, depth: 1 + 2)`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if call.String() != "render(u,compact:true,depth:(1+2))" {
+		t.Errorf("wrong call. got=%s", call.String())
+	}
+	named, ok := call.Arguments[1].(*ast.NamedArgument)
+	if !ok {
+		t.Fatalf("argument is not ast.NamedArgument. got=%T", call.Arguments[1])
+	}
+	testIdentifier(t, named.Name, "compact")
+	testBooleanLiteral(t, named.Value, true)
+
+	p = New(lexer.New("f(a: 1, 2)"))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "line 1: positional argument follows named argument" {
+		t.Errorf("wrong errors. got=%q", p.Errors())
+	}
+	if col := p.ParseErrors()[0].Token.Column; col != 9 {
+		t.Errorf("wrong error column. got=%d", col)
+	}
+}
+
+func TestPatternParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		names    string // 束縛する名前
+	}{
+		{"let [a, b = 1, ...rest] = x", "let [a,b=1,...rest] = x;", "[a b rest]"},
+		{"let {name, age: a = 0, ...rest} = u", "let {name,age:a=0,...rest} = u;", "[name a rest]"},
+		{"let [{name}, [x, y = name]] = v", "let [{name},[x,y=name]] = v;", "[name x y]"},
+		{"let [] = v", "let [] = v;", "[]"},
+		{"fn([a, b], {c} = {}) {}", "fn([a,b],{c}={})", "[a b c]"},
+		{"for ([k, v] in xs) {}", "for([k,v] in xs) ", "[k v]"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%s, got=%s", tt.expected, program.String())
+		}
+
+		names := []string{}
+		ast.Inspect(program, func(node ast.Node) bool {
+			var patterns []ast.Pattern
+			switch node := node.(type) {
+			case *ast.LetStatement:
+				patterns = []ast.Pattern{node.Pattern}
+			case *ast.FunctionLiteral:
+				patterns = node.Parameters
+			case *ast.ForExpression:
+				patterns = []ast.Pattern{node.Variable}
+			}
+			for _, pattern := range patterns {
+				for _, name := range ast.Names(pattern) {
+					names = append(names, name.Value)
+				}
+			}
+			return true
+		})
+		if fmt.Sprint(names) != tt.names {
+			t.Errorf("%s: wrong names. want=%s, got=%v", tt.input, tt.names, names)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let [1] = x", "line 1: expected pattern, got \"1\""},
+		{"let {1} = x", "line 1: expected key name, got \"1\""},
+		{"let [...a, b] = x", "line 1: current token is: \"a\", expected next token is: \"]\", got \",\"(\",\")"},
+		{"for (1 in xs) {}", "line 1: expected pattern, got \"1\""},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%s: wrong errors. want=%q, got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -697,6 +875,199 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:n]", "(a[:n])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::-1]", "(a[::(-1)])"},
+		{"a[1:2:]", "(a[1:2])"},
+		{"a[i+1:j*2:k]", "(a[(i+1):(j*2):k])"},
+		{"a[1:][0]", "((a[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("wrong slice expression. want=%s, got=%s", tt.expected, stmt.Expression.String())
+		}
+	}
+}
+
+func TestParsingSetLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"#{}", "#{}"},
+		{"#{1, 2 * 3, \"a\",}", "#{1,(2*3),a}"},
+		{"#{#{1}, [2]}", "#{#{1},[2]}"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		set, ok := stmt.Expression.(*ast.SetLiteral)
+		if !ok {
+			t.Fatalf("exp not ast.SetLiteral. got=%T", stmt.Expression)
+		}
+		if set.String() != tt.expected {
+			t.Errorf("wrong set literal. want=%s, got=%s", tt.expected, set.String())
+		}
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	p := New(lexer.New(`for (x in 0..<n) { x * 2 }`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.ForExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.ForExpression. got=%T", stmt.Expression)
+	}
+	testIdentifier(t, exp.Variable, "x")
+	if exp.Iterable.String() != "(0..<n)" {
+		t.Errorf("wrong iterable. got=%s", exp.Iterable.String())
+	}
+	if len(exp.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statements. got=%d", len(exp.Body.Statements))
+	}
+	body := exp.Body.Statements[0].(*ast.ExpressionStatement)
+	testInfixExpression(t, body.Expression, "x", "*", 2)
+}
+
+func TestMatchExpression(t *testing.T) {
+	p := New(lexer.New(`match (x) { 1 | -2 => "small", [h, ...t] if h > 0 => h, Integer(n) => n, _ => 0, }`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T", stmt.Expression)
+	}
+	testIdentifier(t, exp.Subject, "x")
+
+	tests := []struct {
+		pattern string
+		guard   string
+		value   string
+	}{
+		{"1|(-2)", "", "small"},
+		{"[h,...t]", "(h>0)", "h"},
+		{"Integer(n)", "", "n"},
+		{"_", "", "0"},
+	}
+	if len(exp.Arms) != len(tests) {
+		t.Fatalf("wrong number of arms. want=%d, got=%d", len(tests), len(exp.Arms))
+	}
+	for i, tt := range tests {
+		arm := exp.Arms[i]
+		if arm.Pattern.String() != tt.pattern {
+			t.Errorf("arms[%d]: wrong pattern. want=%s, got=%s", i, tt.pattern, arm.Pattern.String())
+		}
+		guard := ""
+		if arm.Guard != nil {
+			guard = arm.Guard.String()
+		}
+		if guard != tt.guard {
+			t.Errorf("arms[%d]: wrong guard. want=%s, got=%s", i, tt.guard, guard)
+		}
+		if arm.Value.String() != tt.value {
+			t.Errorf("arms[%d]: wrong value. want=%s, got=%s", i, tt.value, arm.Value.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 1 }", "line 1: current token is: \"1\", expected next token is: \"=>\", got \"}\"(\"}\")"},
+		{"match (x) { [a] | [b] => 0 }", "line 1: alternative [b] binds different names from [a]"},
+		{"match (x) { -a => 0 }", "line 1: current token is: \"-\", expected next token is: \"INT\", got \"IDENT\"(\"a\")"},
+		{"match (x) { (1) => 0 }", "line 1: expected pattern, got \"(\""},
+		{"let [1] = x", "line 1: expected pattern, got \"1\""},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%s: wrong errors. want=%q, got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestMatchAlternativeErrorRecovery(t *testing.T) {
+	// 選択肢の誤りは1つだけ報告し, 続く腕や文はそのまま読む
+	inputs := []string{
+		"match (x) { [a, b] | {a} => a, _ => 0 }\nlet y = 1",
+		"match (x) { [a] | [b] | [c] => 0, 1 | 2 => 1 }",
+	}
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 1 {
+			t.Errorf("%q: expected 1 error. got=%q", input, p.Errors())
+			continue
+		}
+		match := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+		if len(match.Arms) != 2 {
+			t.Errorf("%q: expected 2 arms. got=%d", input, len(match.Arms))
+		}
+	}
+}
+
+func TestYieldStatements(t *testing.T) {
+	tests := []struct {
+		input     string
+		generator []bool // 出現順の関数がgeneratorか
+	}{
+		{`fn() { yield 1; }`, []bool{true}},
+		{`fn() { if (x) { yield x } }`, []bool{true}},
+		{`fn() { for (x in xs) { yield x * 2 } }`, []bool{true}},
+		{`fn() { return 1 }`, []bool{false}},
+		// 入れ子の関数のyieldは外側をgeneratorにしない
+		{`fn() { fn() { yield 1 } }`, []bool{false, true}},
+		{`fn() { yield fn() { 1 } }`, []bool{true, false}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		generator := []bool{}
+		ast.Inspect(program, func(node ast.Node) bool {
+			if fn, ok := node.(*ast.FunctionLiteral); ok {
+				generator = append(generator, fn.Generator)
+			}
+			return true
+		})
+		if fmt.Sprint(generator) != fmt.Sprint(tt.generator) {
+			t.Errorf("wrong generator flags of %s. want=%v, got=%v", tt.input, tt.generator, generator)
+		}
+	}
+
+	p := New(lexer.New(`yield 1`))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "line 1: yield outside function" {
+		t.Errorf("wrong errors. got=%q", p.Errors())
+	}
+}
+
 func TestParsingEmptyArrayLiterals(t *testing.T) {
 	input := "[]"
 
@@ -739,6 +1110,28 @@ func TestParsingArrayLiterals(t *testing.T) {
 	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
+func TestParsingTrailingCommas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2,]", "[1,2]"},
+		{"add(1,\n 2,\n)", "add(1,2)"},
+		{`{"a": 1, "b": 2,}`, `{a:1,b:2}`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestParsingIndexExpressions(t *testing.T) {
 	input := "myArray[1 + 1]"
 
@@ -762,6 +1155,27 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingHashLiteralKeys(t *testing.T) {
+	input := `let h = {"b": 1, "a": 2, 3: 4}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	hash, ok := program.Statements[0].(*ast.LetStatement).Value.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", program.Statements[0].(*ast.LetStatement).Value)
+	}
+	keys := []string{}
+	for _, key := range hash.Keys {
+		keys = append(keys, key.String())
+	}
+	if strings.Join(keys, " ") != "b a 3" {
+		t.Errorf("hash.Keys are not in source order. got=%v", keys)
+	}
+}
+
 // func TestParsingEmptyHashLiteral(t *testing.T) {
 // 	input := "{}"
 
@@ -936,6 +1350,36 @@ func TestParsingIndexExpressions(t *testing.T) {
 // 	}
 // }
 
+func TestTraceOutput(t *testing.T) {
+	expected := "BEGIN parseExpressionStatement\n" +
+		"\tBEGIN parseExpression\n" +
+		"\t\tBEGIN parseIntegerLiteral\n" +
+		"\t\tEND parseIntegerLiteral\n" +
+		"\tEND parseExpression\n" +
+		"END parseExpressionStatement\n"
+
+	// 並行に走らせても各Parserのtraceが混ざらない
+	outputs := make([]bytes.Buffer, 8)
+	var wg sync.WaitGroup
+	for i := range outputs {
+		wg.Add(1)
+		go func(out *bytes.Buffer) {
+			defer wg.Done()
+			p := New(lexer.New("1"))
+			p.SetTraceOutput(out)
+			p.ParseProgram()
+		}(&outputs[i])
+	}
+	New(lexer.New("2")).ParseProgram()
+	wg.Wait()
+
+	for i, out := range outputs {
+		if out.String() != expected {
+			t.Errorf("wrong trace of parser %d.\nwant=%q\ngot =%q", i, expected, out.String())
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
@@ -2,31 +2,40 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"strings"
 )
 
-var traceLevel int = 0
-
 const traceIdentPlaceholder string = "\t"
 
-func identLevel() string {
-	return strings.Repeat(traceIdentPlaceholder, traceLevel-1)
+// SetTraceOutput enables tracing of this parser to w. pass nil to disable it.
+func (p *Parser) SetTraceOutput(w io.Writer) {
+	p.traceOut = w
+	p.traceLevel = 0
+}
+
+func (p *Parser) identLevel() string {
+	return strings.Repeat(traceIdentPlaceholder, p.traceLevel-1)
 }
 
-func tracePrint(fs string) {
-	fmt.Printf("%s%s\n", identLevel(), fs)
+// traceOutがnilの間はtraceを出力しない
+func (p *Parser) tracePrint(fs string) {
+	if p.traceOut == nil {
+		return
+	}
+	fmt.Fprintf(p.traceOut, "%s%s\n", p.identLevel(), fs)
 }
 
-func incIdent() { traceLevel = traceLevel + 1 }
-func decIdent() { traceLevel = traceLevel - 1 }
+func (p *Parser) incIdent() { p.traceLevel = p.traceLevel + 1 }
+func (p *Parser) decIdent() { p.traceLevel = p.traceLevel - 1 }
 
-func trace(msg string) string {
-	incIdent()
-	tracePrint("BEGIN " + msg)
+func (p *Parser) trace(msg string) string {
+	p.incIdent()
+	p.tracePrint("BEGIN " + msg)
 	return msg
 }
 
-func untrace(msg string) {
-	tracePrint("END " + msg)
-	decIdent()
+func (p *Parser) untrace(msg string) {
+	p.tracePrint("END " + msg)
+	p.decIdent()
 }
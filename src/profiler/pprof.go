@@ -0,0 +1,166 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// profile.protoのfield番号
+// https://github.com/google/pprof/blob/main/proto/profile.proto
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// WritePprof writes the profile in the gzipped protobuf format read by `go tool pprof`.
+// samples have two values: calls/count and time/nanoseconds spent in the function itself.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	table := &stringTable{index: map[string]int{}}
+	table.add("")
+
+	var profile protobuf
+	for _, vt := range [][2]string{{"calls", "count"}, {"time", "nanoseconds"}} {
+		var valueType protobuf
+		valueType.int(valueTypeType, int64(table.add(vt[0])))
+		valueType.int(valueTypeUnit, int64(table.add(vt[1])))
+		profile.message(profileSampleType, valueType)
+	}
+
+	for _, key := range p.order {
+		s := p.samples[key]
+		var sample protobuf
+		ids := make([]uint64, 0, len(s.locations))
+		for _, id := range s.locations {
+			ids = append(ids, uint64(id))
+		}
+		sample.packed(sampleLocationID, ids)
+		sample.packed(sampleValue, []uint64{uint64(s.calls), uint64(s.nanos)})
+		profile.message(profileSample, sample)
+	}
+
+	// 関数ごとに1つのlocationを作り, idを共有する
+	for i, f := range p.functions {
+		id := int64(i + 1)
+		var line protobuf
+		line.int(lineFunctionID, id)
+		line.int(lineLine, int64(f.Line))
+		var location protobuf
+		location.int(locationID, id)
+		location.message(locationLine, line)
+		profile.message(profileLocation, location)
+	}
+	for i, f := range p.functions {
+		var function protobuf
+		function.int(functionID, int64(i+1))
+		function.int(functionName, int64(table.add(f.String())))
+		function.int(functionSystemName, int64(table.add(f.Name)))
+		if !f.Builtin && f.Name != MAIN {
+			function.int(functionFilename, int64(table.add(f.File)))
+			function.int(functionStartLine, int64(f.Line))
+		}
+		profile.message(profileFunction, function)
+	}
+
+	var periodType protobuf
+	periodType.int(valueTypeType, int64(table.add("time")))
+	periodType.int(valueTypeUnit, int64(table.add("nanoseconds")))
+
+	for _, s := range table.values {
+		profile.bytes(profileStringTable, []byte(s))
+	}
+	profile.int(profileTimeNanos, p.start.UnixNano())
+	profile.int(profileDurationNanos, int64(p.duration))
+	profile.message(profilePeriodType, periodType)
+	profile.int(profilePeriod, 1)
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(profile); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type stringTable struct {
+	values []string
+	index  map[string]int
+}
+
+func (t *stringTable) add(s string) int {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+	t.values = append(t.values, s)
+	t.index[s] = len(t.values) - 1
+	return len(t.values) - 1
+}
+
+// protocol buffersのwire format. 使う型だけ実装する
+type protobuf []byte
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protobuf) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *protobuf) key(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protobuf) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	b.key(field, wireVarint)
+	b.varint(uint64(v))
+}
+
+func (b *protobuf) bytes(field int, v []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *protobuf) message(field int, m protobuf) {
+	b.bytes(field, m)
+}
+
+func (b *protobuf) packed(field int, values []uint64) {
+	var data protobuf
+	for _, v := range values {
+		data.varint(v)
+	}
+	b.bytes(field, data)
+}
@@ -0,0 +1,195 @@
+// Package profiler measures time and calls of choco functions and builtins.
+package profiler
+
+import (
+	"choco/src/ast"
+	"choco/src/object"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MAIN is the name of the frame evaluating top-level statements
+const MAIN = "main"
+
+// Function identifies a choco function by its binding name and definition position, or a builtin
+type Function struct {
+	Name    string
+	File    string
+	Line    int // builtinは0
+	Column  int
+	Builtin bool
+}
+
+func (f Function) String() string {
+	if f.Builtin || f.Line == 0 {
+		return f.Name
+	}
+	return fmt.Sprintf("%s (%s:%d:%d)", f.Name, f.File, f.Line, f.Column)
+}
+
+// Stat is the time and calls attributed to a function
+type Stat struct {
+	Function Function
+	Calls    int
+	Flat     time.Duration // 関数自身で使った時間
+	Cum      time.Duration // 呼び出した関数を含む時間
+}
+
+// Profiler is an instrumenting profiler. install it with evaluator.SetHook between Start and Stop.
+type Profiler struct {
+	file      string
+	start     time.Time
+	duration  time.Duration
+	functions []Function       // idは添字+1
+	ids       map[Function]int // Functionからid
+	stack     []*frame
+	samples   map[string]*sample // 呼び出し経路ごとの集計
+	order     []string
+	stats     map[int]*Stat
+	active    map[int]int // 実行中の呼び出しの数. 再帰でCumを重複して数えない
+	now       func() time.Time
+}
+
+type frame struct {
+	id       int
+	start    time.Time
+	children time.Duration
+}
+
+// 同じ呼び出し経路の集計. locationsは呼び出された側から
+type sample struct {
+	locations []int
+	calls     int64
+	nanos     int64
+}
+
+// New creates a profiler for a program read from file
+func New(file string) *Profiler {
+	return &Profiler{
+		file:    file,
+		ids:     map[Function]int{},
+		samples: map[string]*sample{},
+		stats:   map[int]*Stat{},
+		active:  map[int]int{},
+		now:     time.Now,
+	}
+}
+
+// Start begins measuring the top-level statements
+func (p *Profiler) Start() {
+	p.push(Function{Name: MAIN})
+	p.start = p.stack[0].start
+}
+
+// Stop ends measuring. frames left by a runtime error are closed too.
+func (p *Profiler) Stop() {
+	for len(p.stack) > 0 {
+		p.duration = p.pop()
+	}
+}
+
+// Stats returns stats of every called function sorted by flat time
+func (p *Profiler) Stats() []Stat {
+	stats := []Stat{}
+	for _, s := range p.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Flat != stats[j].Flat {
+			return stats[i].Flat > stats[j].Flat
+		}
+		return stats[i].Function.String() < stats[j].Function.String()
+	})
+	return stats
+}
+
+// Statement implements evaluator.Hook
+func (p *Profiler) Statement(stmt ast.Statement, env *object.Environment) {}
+
+// Branch implements evaluator.Hook
+func (p *Profiler) Branch(node *ast.IfExpression, consequence bool) {}
+
+// Error implements evaluator.Hook
+func (p *Profiler) Error(err *object.Error) {}
+
+// Call implements evaluator.Hook
+func (p *Profiler) Call(call *ast.CallExpression, fn object.Object, args []object.Object, env *object.Environment) {
+	p.push(p.function(fn))
+}
+
+// Return implements evaluator.Hook
+func (p *Profiler) Return(call *ast.CallExpression, fn object.Object, result object.Object) {
+	// Startより前の呼び出しやmainは閉じない
+	if len(p.stack) > 1 {
+		p.pop()
+	}
+}
+
+func (p *Profiler) function(fn object.Object) Function {
+	switch fn := fn.(type) {
+	case *object.Function:
+		name := fn.Name
+		if name == "" {
+			name = "fn"
+		}
+		return Function{Name: name, File: p.file, Line: fn.Position.Line, Column: fn.Position.Column}
+	case *object.Builtin:
+		return Function{Name: fn.Name, Builtin: true}
+	default:
+		return Function{Name: string(fn.Type())}
+	}
+}
+
+func (p *Profiler) push(f Function) {
+	id, ok := p.ids[f]
+	if !ok {
+		p.functions = append(p.functions, f)
+		id = len(p.functions)
+		p.ids[f] = id
+	}
+	p.stack = append(p.stack, &frame{id: id, start: p.now()})
+	p.active[id]++
+}
+
+func (p *Profiler) pop() time.Duration {
+	top := p.stack[len(p.stack)-1]
+	elapsed := p.now().Sub(top.start)
+	flat := elapsed - top.children
+
+	// 呼び出し経路. pprofに合わせて呼び出された側から並べる
+	locations := make([]int, 0, len(p.stack))
+	keys := make([]string, 0, len(p.stack))
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		locations = append(locations, p.stack[i].id)
+		keys = append(keys, fmt.Sprint(p.stack[i].id))
+	}
+	key := strings.Join(keys, ",")
+	s, ok := p.samples[key]
+	if !ok {
+		s = &sample{locations: locations}
+		p.samples[key] = s
+		p.order = append(p.order, key)
+	}
+	s.calls++
+	s.nanos += int64(flat)
+
+	stat, ok := p.stats[top.id]
+	if !ok {
+		stat = &Stat{Function: p.functions[top.id-1]}
+		p.stats[top.id] = stat
+	}
+	stat.Calls++
+	stat.Flat += flat
+	p.active[top.id]--
+	if p.active[top.id] == 0 {
+		stat.Cum += elapsed
+	}
+
+	p.stack = p.stack[:len(p.stack)-1]
+	if len(p.stack) > 0 {
+		p.stack[len(p.stack)-1].children += elapsed
+	}
+	return elapsed
+}
@@ -0,0 +1,109 @@
+package profiler
+
+import (
+	"bytes"
+	"choco/src/evaluator"
+	"choco/src/lexer"
+	"choco/src/object"
+	"choco/src/parser"
+	"compress/gzip"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const source = `let double = fn(x) { x * 2 }
+let sum = fn(xs) {
+    if (len(xs) == 0) {
+        return 0
+    }
+    first(xs) + sum(rest(xs))
+}
+sum([double(1), double(2)])
+`
+
+// 呼ばれるたびに1msずつ進む時計で計測する
+func profile(t *testing.T, src string) *Profiler {
+	t.Helper()
+	program := parser.New(lexer.New(src)).ParseProgram()
+
+	p := New("sum.choco")
+	clock := time.Unix(0, 0)
+	p.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	evaluator.SetHook(p)
+	defer evaluator.SetHook(nil)
+	p.Start()
+	evaluator.Eval(program, object.NewEnvironment())
+	p.Stop()
+	return p
+}
+
+func TestStats(t *testing.T) {
+	p := profile(t, source)
+
+	calls := map[string]int{}
+	for _, s := range p.Stats() {
+		calls[s.Function.String()] = s.Calls
+	}
+	expected := map[string]int{
+		"main":                    1,
+		"double (sum.choco:1:14)": 2,
+		"sum (sum.choco:2:11)":    3,
+		"len":                     3,
+		"first":                   2,
+		"rest":                    2,
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("wrong calls.\nwant=%v\ngot =%v", expected, calls)
+	}
+
+	var total time.Duration
+	for _, s := range p.Stats() {
+		total += s.Flat
+		if s.Function.Name == MAIN && s.Cum != p.duration {
+			t.Errorf("main should include everything. cum=%s, duration=%s", s.Cum, p.duration)
+		}
+		if s.Function.Name == "sum" && s.Cum >= p.duration {
+			t.Errorf("recursive calls should not be counted twice. cum=%s, duration=%s", s.Cum, p.duration)
+		}
+	}
+	if total != p.duration {
+		t.Errorf("flat times should add up to the duration. total=%s, duration=%s", total, p.duration)
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	p := profile(t, "let f = fn() { 1 + true }\nf()")
+	for _, s := range p.Stats() {
+		if s.Calls != 1 {
+			t.Errorf("wrong calls of %s: %d", s.Function, s.Calls)
+		}
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	p := profile(t, source)
+
+	var buf bytes.Buffer
+	if err := p.WritePprof(&buf); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"calls", "nanoseconds", "sum (sum.choco:2:11)", "sum.choco", "first"} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("profile doesn't contain %q", s)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package repl
+
+import (
+	"choco/src/evaluator"
+	"choco/src/object"
+	"choco/src/runner"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// `:` から始まる入力はREPL自体への命令として扱う
+const META_COMMAND_PREFIX = ":"
+
+type metaCommand struct {
+	usage   string
+	summary string
+	run     func(s *session, arg string)
+}
+
+var metaCommands map[string]*metaCommand
+
+func init() {
+	metaCommands = map[string]*metaCommand{
+		"help":   {usage: ":help", summary: "show this help", run: helpMetaCommand},
+		"load":   {usage: ":load file.choco", summary: "evaluate a file into the current environment", run: loadMetaCommand},
+		"env":    {usage: ":env", summary: "list bindings of the current environment", run: envMetaCommand},
+		"ast":    {usage: ":ast expr", summary: "show parsed statements of expr", run: astMetaCommand},
+		"tokens": {usage: ":tokens expr", summary: "show tokens of expr", run: tokensMetaCommand},
+		"type":   {usage: ":type expr", summary: "show the type of the value of expr", run: typeMetaCommand},
+		"reset":  {usage: ":reset", summary: "start a fresh environment", run: resetMetaCommand},
+		"time":   {usage: ":time expr", summary: "evaluate expr and show the elapsed time", run: timeMetaCommand},
+		"save":   {usage: ":save file.choco", summary: "write successful inputs of this session to a file", run: saveMetaCommand},
+	}
+}
+
+func isMetaCommand(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), META_COMMAND_PREFIX)
+}
+
+func (s *session) runMetaCommand(input string) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), META_COMMAND_PREFIX)
+	name, arg := input, ""
+	if idx := strings.IndexAny(input, " \t\n"); idx >= 0 {
+		name, arg = input[:idx], strings.TrimSpace(input[idx+1:])
+	}
+
+	cmd, ok := metaCommands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command: :%s. type :help to list commands\n", name)
+		return
+	}
+	cmd.run(s, arg)
+}
+
+func helpMetaCommand(s *session, arg string) {
+	names := make([]string, 0, len(metaCommands))
+	for name := range metaCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cmd := metaCommands[name]
+		fmt.Fprintf(s.out, "  %-18s %s\n", cmd.usage, cmd.summary)
+	}
+}
+
+func loadMetaCommand(s *session, arg string) {
+	if arg == "" {
+		fmt.Fprintf(s.out, "usage: %s\n", metaCommands["load"].usage)
+		return
+	}
+
+	src, err := ioutil.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintf(s.out, "couldn't read %s: %s\n", arg, err)
+		return
+	}
+
+	if evaluated, ok := s.eval(string(src)); ok {
+		s.inputs = append(s.inputs, string(src))
+		s.print(evaluated)
+	}
+}
+
+func envMetaCommand(s *session, arg string) {
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s: %s = %s\n", name, val.Type(), val.Inspect())
+	}
+}
+
+func astMetaCommand(s *session, arg string) {
+	if program := s.parse(arg); program != nil {
+		runner.DumpAST(s.out, program)
+	}
+}
+
+func tokensMetaCommand(s *session, arg string) {
+	runner.DumpTokens(s.out, arg)
+}
+
+func typeMetaCommand(s *session, arg string) {
+	program := s.parse(arg)
+	if program == nil {
+		return
+	}
+
+	// 型を調べるだけなのでsessionのenvは汚さない
+	evaluated := evaluator.Eval(program, object.NewEnclosedEnvironment(s.env))
+	if evaluated == nil {
+		fmt.Fprintf(s.out, "%s\n", object.NULL_OBJ)
+		return
+	}
+	fmt.Fprintf(s.out, "%s\n", evaluated.Type())
+}
+
+func resetMetaCommand(s *session, arg string) {
+	s.env = object.NewEnvironment()
+	s.inputs = []string{}
+}
+
+func timeMetaCommand(s *session, arg string) {
+	start := time.Now()
+	evaluated, ok := s.eval(arg)
+	elapsed := time.Since(start)
+
+	if ok {
+		s.inputs = append(s.inputs, arg)
+		s.print(evaluated)
+	}
+	fmt.Fprintf(s.out, "elapsed: %s\n", elapsed)
+}
+
+func saveMetaCommand(s *session, arg string) {
+	if arg == "" {
+		fmt.Fprintf(s.out, "usage: %s\n", metaCommands["save"].usage)
+		return
+	}
+
+	content := strings.Join(s.inputs, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	if err := ioutil.WriteFile(arg, []byte(content), 0644); err != nil {
+		fmt.Fprintf(s.out, "couldn't write %s: %s\n", arg, err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %d inputs to %s\n", len(s.inputs), arg)
+}
@@ -0,0 +1,143 @@
+package repl
+
+import (
+	"choco/src/evaluator"
+	"choco/src/lexer"
+	"choco/src/object"
+	"choco/src/parser"
+	"choco/src/token"
+	"sort"
+	"strings"
+)
+
+// complete returns completion candidates for line at cursor position pos.
+// head and tail are the parts of line that are kept as they are.
+func (s *session) complete(line string, pos int) (head string, completions []string, tail string) {
+	before, tail := line[:pos], line[pos:]
+
+	if strings.HasPrefix(before, META_COMMAND_PREFIX) && !strings.ContainsAny(before, " \t") {
+		return META_COMMAND_PREFIX, completeMetaCommand(before[len(META_COMMAND_PREFIX):]), tail
+	}
+
+	// expr["... の形ならhashのkeyを補完する
+	if idx := strings.LastIndex(before, "[\""); idx >= 0 && !strings.Contains(before[idx+2:], "\"") {
+		head, completions = s.completeHashKey(before, idx, tail)
+		return head, completions, tail
+	}
+
+	word := lastWord(before)
+	head = before[:len(before)-len(word)]
+	return head, s.completeIdentifier(word), tail
+}
+
+func completeMetaCommand(prefix string) []string {
+	names := map[string]bool{}
+	for name := range metaCommands {
+		names[name] = true
+	}
+	return filterPrefix(names, prefix)
+}
+
+func (s *session) completeIdentifier(prefix string) []string {
+	candidates := map[string]bool{}
+	s.env.Each(func(name string, val object.Object) {
+		candidates[name] = true
+	})
+	for _, name := range evaluator.BuiltinNames() {
+		candidates[name] = true
+	}
+	for _, word := range token.Keywords() {
+		candidates[word] = true
+	}
+	return filterPrefix(candidates, prefix)
+}
+
+// before[idx:]が `["` から始まる. その直前の式を評価してhashのkeyを候補にする
+func (s *session) completeHashKey(before string, idx int, tail string) (string, []string) {
+	head := before[:idx+2]
+	prefix := before[idx+2:]
+
+	expr := before[expressionStart(before[:idx]):idx]
+	// 補完のためにユーザーの関数を実行してしまわないよう呼び出しを含む式は評価しない
+	if expr == "" || strings.Contains(expr, "(") {
+		return head, nil
+	}
+
+	p := parser.New(lexer.New(expr))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return head, nil
+	}
+	hash, ok := evaluator.Eval(program, object.NewEnclosedEnvironment(s.env)).(*object.Hash)
+	if !ok {
+		return head, nil
+	}
+
+	keys := map[string]bool{}
+	for _, pair := range hash.Pairs() {
+		if key, ok := pair.Key.(*object.String); ok {
+			keys[key.Value] = true
+		}
+	}
+
+	completions := filterPrefix(keys, prefix)
+	if !strings.HasPrefix(tail, "\"") {
+		for i, key := range completions {
+			completions[i] = key + "\"]"
+		}
+	}
+	return head, completions
+}
+
+// sの末尾にある `users[0]["address"]` のような識別子とindexの連なりの開始位置を返す
+func expressionStart(s string) int {
+	i := len(s)
+	for i > 0 {
+		ch := s[i-1]
+		switch {
+		case ch == ']':
+			depth := 0
+			for i > 0 {
+				i -= 1
+				if s[i] == ']' {
+					depth += 1
+				} else if s[i] == '[' {
+					depth -= 1
+					if depth == 0 {
+						break
+					}
+				}
+			}
+		case isIdentifierChar(ch):
+			i -= 1
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func lastWord(s string) string {
+	i := len(s)
+	for i > 0 && isIdentifierChar(s[i-1]) {
+		i -= 1
+	}
+	// 識別子は英字から始まる. 先頭の!は否定演算子
+	return strings.TrimLeft(s[i:], "!?0123456789")
+}
+
+// lexerのisLetterに数字を加えたもの. indexの数字も式の一部として扱う
+func isIdentifierChar(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '_' || ch == '?' || ch == '!'
+}
+
+func filterPrefix(candidates map[string]bool, prefix string) []string {
+	matched := []string{}
+	for candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matched = append(matched, candidate)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
@@ -0,0 +1,63 @@
+package repl
+
+import (
+	"choco/src/lexer"
+	"choco/src/token"
+	"strings"
+)
+
+// 行末に来た場合に次の行へ続くことを示すtoken
+var continuationTokens = map[token.TokenType]bool{
+	token.ASSIGN:    true,
+	token.PLUS:      true,
+	token.MINUS:     true,
+	token.BANG:      true,
+	token.ASTERISK:  true,
+	token.SLASH:     true,
+	token.PIPE:      true,
+	token.DOTDOT:    true,
+	token.DOTDOTLT:  true,
+	token.AMPERSAND: true,
+	token.LT:        true,
+	token.GT:        true,
+	token.LTEQ:      true,
+	token.GTEQ:      true,
+	token.EQ:        true,
+	token.NOT_EQ:    true,
+	token.COMMA:     true,
+	token.COLON:     true,
+}
+
+// isIncomplete reports whether input needs more lines to be parsed:
+// an unterminated string, unbalanced brackets or a trailing operator.
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
+	last := token.Token{Type: token.EOF}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET, token.SET_LBRACE:
+			depth += 1
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth -= 1
+		}
+		last = tok
+	}
+
+	if depth > 0 || last.Type == token.STRING && unterminated(input, last) {
+		return true
+	}
+	return continuationTokens[last.Type]
+}
+
+// lexerは閉じていない文字列を入力の最後までの文字列にするので,
+// 閉じる"があるはずの位置が入力の外なら閉じていない
+func unterminated(input string, str token.Token) bool {
+	offset := 0
+	for _, line := range strings.SplitAfter(input, "\n")[:str.Line-1] {
+		offset += len(line)
+	}
+	// 開始の" + 中身の後に閉じる"がある
+	closing := offset + str.Column - 1 + 1 + len(str.Literal)
+	return closing >= len(input)
+}
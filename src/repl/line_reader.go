@@ -0,0 +1,91 @@
+package repl
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterh/liner"
+)
+
+const HISTORY_FILE = ".choco_history"
+
+// 1行ずつ入力を受け取る. 端末ではlinerで行編集と履歴を提供する
+type lineReader interface {
+	Prompt(prompt string) (string, error)
+	AppendHistory(input string)
+	Close() error
+}
+
+// 補完候補を返す関数. liner.WordCompleterと同じ形
+type completer func(line string, pos int) (head string, completions []string, tail string)
+
+func newLineReader(in io.Reader, out io.Writer, complete completer) lineReader {
+	if in == os.Stdin {
+		return newTerminalReader(complete)
+	}
+	return &plainReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// pipeやtestなど端末以外からの入力
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *plainReader) Prompt(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+func (r *plainReader) AppendHistory(input string) {}
+
+func (r *plainReader) Close() error { return nil }
+
+type terminalReader struct {
+	state       *liner.State
+	historyPath string
+}
+
+func newTerminalReader(complete completer) *terminalReader {
+	state := liner.NewLiner()
+	state.SetCtrlCAborts(true)
+	state.SetWordCompleter(liner.WordCompleter(complete))
+
+	r := &terminalReader{state: state}
+	if home, err := os.UserHomeDir(); err == nil {
+		r.historyPath = filepath.Join(home, HISTORY_FILE)
+		if f, err := os.Open(r.historyPath); err == nil {
+			state.ReadHistory(f)
+			f.Close()
+		}
+	}
+	return r
+}
+
+func (r *terminalReader) Prompt(prompt string) (string, error) {
+	return r.state.Prompt(prompt)
+}
+
+// 履歴は1行1件で保存されるので複数行の入力は1行にまとめる
+func (r *terminalReader) AppendHistory(input string) {
+	r.state.AppendHistory(strings.Replace(input, "\n", " ", -1))
+}
+
+func (r *terminalReader) Close() error {
+	if r.historyPath != "" {
+		if f, err := os.Create(r.historyPath); err == nil {
+			r.state.WriteHistory(f)
+			f.Close()
+		}
+	}
+	return r.state.Close()
+}
@@ -1,44 +1,124 @@
 package repl
 
 import (
-	"bufio"
+	"choco/src/ast"
 	"choco/src/evaluator"
 	"choco/src/lexer"
 	"choco/src/object"
 	"choco/src/parser"
-	"fmt"
 	"io"
+	"strings"
+
+	"github.com/peterh/liner"
 )
 
 const PROMPT = ">> "
 
+// 入力が完結していない間に表示するprompt
+const CONTINUATION_PROMPT = ".. "
+
+// REPLの状態
+type session struct {
+	env    *object.Environment
+	inputs []string // 評価に成功した入力. :saveで書き出す
+	out    io.Writer
+}
+
+func newSession(out io.Writer) *session {
+	return &session{env: object.NewEnvironment(), inputs: []string{}, out: out}
+}
+
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	s := newSession(out)
+
+	reader := newLineReader(in, out, s.complete)
+	defer reader.Close()
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		input, err := readInput(reader)
+		if err == liner.ErrPromptAborted {
+			continue
+		}
+		if strings.TrimSpace(input) == "" {
+			if err != nil {
+				return
+			}
+			continue
 		}
 
-		line := scanner.Text()
+		reader.AppendHistory(input)
 
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
+		s.handle(input)
 
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
+		// EOFの直前に読んだ入力は評価してから終了する
+		if err != nil {
+			return
 		}
+	}
+}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+// 入力が完結するまで継続行を読み込む
+func readInput(reader lineReader) (string, error) {
+	lines := []string{}
+	prompt := PROMPT
+	for {
+		line, err := reader.Prompt(prompt)
+		if err != nil {
+			return strings.Join(lines, "\n"), err
 		}
+
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		if !isIncomplete(input) {
+			return input, nil
+		}
+		prompt = CONTINUATION_PROMPT
+	}
+}
+
+// 1回分の入力を処理する
+func (s *session) handle(input string) {
+	if isMetaCommand(input) {
+		s.runMetaCommand(input)
+	} else if evaluated, ok := s.eval(input); ok {
+		s.inputs = append(s.inputs, input)
+		s.print(evaluated)
+	}
+}
+
+// parseに失敗した場合はerrorを出力してnilを返す
+func (s *session) parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return nil
+	}
+	return program
+}
+
+// inputを現在のenvで評価する. parseと評価の両方に成功した場合のみokを返す
+// 評価時のerrorは結果として出力される
+func (s *session) eval(input string) (object.Object, bool) {
+	program := s.parse(input)
+	if program == nil {
+		return nil, false
+	}
+
+	evaluated := evaluator.Eval(program, s.env)
+	if evaluated != nil && evaluated.Type() == object.ERROR_OBJ {
+		s.print(evaluated)
+		return nil, false
+	}
+	return evaluated, true
+}
+
+func (s *session) print(evaluated object.Object) {
+	if evaluated != nil {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
 	}
 }
 
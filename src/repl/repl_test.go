@@ -0,0 +1,160 @@
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n  x\n}", false},
+		{"[1, 2,", true},
+		{"{\"name\": \"Tom\",\n\"age\": 10}", false},
+		{"add(1,\n2", true},
+		{"1 +", true},
+		{"let x =", true},
+		{"\"abc", true},
+		{"\"{\"", false},
+		{"\"abc\"\n", false},
+		{"let a = \"\n", true},
+		{"let a = 1; // say \"hi", false},
+		{"// \"\nlet s = \"a\nb", true},
+		{"\"a // b\"", false},
+		{"}", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. want=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x + y
+}
+add(1,
+  2)
+let user = {
+  "name": "Tom"
+}
+user["name"]
+`
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> .. .. >> .. 3\n>> .. .. >> Tom\n>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestMetaCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "choco-repl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lib := filepath.Join(dir, "lib.choco")
+	saved := filepath.Join(dir, "saved.choco")
+	if err := ioutil.WriteFile(lib, []byte("let double = fn(x) { x * 2 };"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":load " + lib, ""},
+		{"let a = double(2)", ""},
+		{"a", "4\n"},
+		{"foobar", "ERROR: identifier not found: foobar\n"},
+		{":env", "a: INTEGER = 4\ndouble: FUNCTION_OBJ = fn(x) {\n(x*2)\n\n"},
+		{":ast 1 + 2 * 3", "(1+(2*3))\n"},
+		{":tokens a;", "IDENT\t\"a\"\n;\t\";\"\nEOF\t\"\"\n"},
+		{":type a", "INTEGER\n"},
+		{":type let b = 1", "NULL\n"},
+		{":save " + saved, "saved 3 inputs to " + saved + "\n"},
+		{":reset", ""},
+		{":env", ""},
+		{":unknown", "unknown command: :unknown. type :help to list commands\n"},
+	}
+
+	s := newSession(nil)
+	for _, tt := range tests {
+		var out bytes.Buffer
+		s.out = &out
+		s.handle(tt.input)
+
+		if out.String() != tt.expected {
+			t.Errorf("%q: wrong output. want=%q, got=%q", tt.input, tt.expected, out.String())
+		}
+	}
+
+	content, err := ioutil.ReadFile(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "let double = fn(x) { x * 2 };\nlet a = double(2)\na\n"
+	if string(content) != expected {
+		t.Errorf("wrong saved content. want=%q, got=%q", expected, string(content))
+	}
+}
+
+func TestTimeMetaCommand(t *testing.T) {
+	var out bytes.Buffer
+	s := newSession(&out)
+	s.runMetaCommand(":time 1 + 2")
+
+	if !strings.HasPrefix(out.String(), "3\nelapsed: ") {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestComplete(t *testing.T) {
+	s := newSession(&bytes.Buffer{})
+	s.eval(`let users = [{"name": "Tom", "nickname": "T", "age": 10}]; let userCount = 1;`)
+	s.eval(`let user = users[0]`)
+
+	tests := []struct {
+		line                string
+		expectedHead        string
+		expectedCompletions []string
+		expectedTail        string
+	}{
+		{"use", "", []string{"user", "userCount", "users"}, ""},
+		{"puts(le", "puts(", []string{"len", "let"}, ""},
+		{"!tr", "!", []string{"true"}, ""},
+		{`user["n`, `user["`, []string{`name"]`, `nickname"]`}, ""},
+		{`users[0]["a`, `users[0]["`, []string{`age"]`}, ""},
+		{`users[len(users) - 1]["a`, `users[len(users) - 1]["`, []string{}, ""},
+		{`userCount["`, `userCount["`, []string{}, ""},
+		{":lo", ":", []string{"load"}, ""},
+	}
+
+	for _, tt := range tests {
+		head, completions, tail := s.complete(tt.line, len(tt.line))
+		if head != tt.expectedHead || tail != tt.expectedTail {
+			t.Errorf("%q: wrong head/tail. want=%q/%q, got=%q/%q", tt.line, tt.expectedHead, tt.expectedTail, head, tail)
+		}
+		if strings.Join(completions, " ") != strings.Join(tt.expectedCompletions, " ") {
+			t.Errorf("%q: wrong completions. want=%v, got=%v", tt.line, tt.expectedCompletions, completions)
+		}
+	}
+
+	head, completions, tail := s.complete(`user["na"] + 1`, 8)
+	if head != `user["` || strings.Join(completions, " ") != "name" || tail != `"] + 1` {
+		t.Errorf("wrong completion in the middle of line. got=%q, %v, %q", head, completions, tail)
+	}
+}
@@ -1,35 +1,140 @@
 package runner
 
 import (
+	"choco/src/ast"
+	"choco/src/coverage"
 	"choco/src/evaluator"
 	"choco/src/lexer"
 	"choco/src/object"
 	"choco/src/parser"
+	"choco/src/profiler"
+	"choco/src/token"
+	"choco/src/tracer"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 )
 
-func Run(filepath string, in io.Reader, out io.Writer) {
-	bytes, err := ioutil.ReadFile(filepath)
+// Options controls how a choco program is executed
+type Options struct {
+	Args        []string // script arguments, exposed as `args`
+	Quiet       bool     // do not print the value of the last statement
+	TraceParser bool     // print parser trace to errOut
+	Trace       bool     // print evaluation trace to errOut
+	DumpTokens  bool     // print tokens and exit without evaluating
+	DumpAST     bool     // print statements and exit without evaluating
+	Strict      bool     // make out-of-range indexes and slices errors instead of null
+
+	Filename string             // name of the program used in reports
+	Coverage *coverage.Recorder // records statements and branches which ran when not nil
+	Profiler *profiler.Profiler // measures function calls when not nil
+}
+
+// ReadSource reads the program from filepath. "-" means reading from in.
+func ReadSource(filepath string, in io.Reader) (string, error) {
+	var bytes []byte
+	var err error
+	if filepath == "-" {
+		bytes, err = ioutil.ReadAll(in)
+	} else {
+		bytes, err = ioutil.ReadFile(filepath)
+	}
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// Run evaluates input and returns exit status of the program
+func Run(input string, opts Options, out io.Writer, errOut io.Writer) int {
+	if opts.DumpTokens {
+		DumpTokens(out, input)
+		return 0
 	}
-	input := string(bytes)
 
-	env := object.NewEnvironment()
 	l := lexer.New(input)
 	p := parser.New(l)
+	if opts.TraceParser {
+		p.SetTraceOutput(errOut)
+	}
 	program := p.ParseProgram()
 
 	if len(p.Errors()) != 0 {
-		printParserErrors(out, p.Errors())
+		printParserErrors(errOut, p.Errors())
+		return 1
+	}
+
+	if opts.DumpAST {
+		DumpAST(out, program)
+		return 0
 	}
 
+	env := object.NewEnvironment()
+	env.Set("args", NewArgsArray(opts.Args))
+
+	evaluator.SetOutput(out)
+	defer evaluator.SetOutput(os.Stdout)
+	if opts.Strict {
+		evaluator.SetStrict(true)
+		defer evaluator.SetStrict(false)
+	}
+	hooks := evaluator.Hooks{}
+	if opts.Coverage != nil {
+		opts.Coverage.Add(opts.Filename, program)
+		hooks = append(hooks, opts.Coverage)
+	}
+	if opts.Trace {
+		hooks = append(hooks, tracer.New(errOut))
+	}
+	if opts.Profiler != nil {
+		hooks = append(hooks, opts.Profiler)
+		opts.Profiler.Start()
+		defer opts.Profiler.Stop()
+	}
+	if len(hooks) != 0 {
+		evaluator.SetHook(hooks)
+		defer evaluator.SetHook(nil)
+	}
 	evaluated := evaluator.Eval(program, env)
-	if evaluated != nil {
+	if errObj, ok := evaluated.(*object.Error); ok {
+		io.WriteString(errOut, errObj.Inspect())
+		io.WriteString(errOut, "\n")
+		return 1
+	}
+	if evaluated != nil && !opts.Quiet {
 		io.WriteString(out, evaluated.Inspect())
 		io.WriteString(out, "\n")
 	}
+	return 0
+}
+
+// NewArgsArray converts script arguments to the `args` array
+func NewArgsArray(args []string) *object.Array {
+	elements := make([]object.Object, 0, len(args))
+	for _, arg := range args {
+		elements = append(elements, &object.String{Value: arg})
+	}
+	return &object.Array{Elements: elements}
+}
+
+// DumpTokens prints every token of input, one per line
+func DumpTokens(out io.Writer, input string) {
+	l := lexer.New(input)
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		fmt.Fprintf(out, "%s\t%q\n", tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+// DumpAST prints each statement of program, one per line
+func DumpAST(out io.Writer, program *ast.Program) {
+	for _, stmt := range program.Statements {
+		io.WriteString(out, stmt.String())
+		io.WriteString(out, "\n")
+	}
 }
 
 func printParserErrors(out io.Writer, errors []string) {
@@ -0,0 +1,156 @@
+// Package tester runs tests written in choco with the test builtin.
+package tester
+
+import (
+	"choco/src/ast"
+	"choco/src/coverage"
+	"choco/src/evaluator"
+	"choco/src/lexer"
+	"choco/src/object"
+	"choco/src/parser"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FILE_SUFFIX is the suffix of test files
+const FILE_SUFFIX = "_test.choco"
+
+// Options controls which tests are run and how results are printed
+type Options struct {
+	Run     *regexp.Regexp // nilの場合は全てのtestを実行する
+	Verbose bool           // 成功したtestも表示する
+
+	Coverage *coverage.Recorder // nilでなければ実行された文と分岐を記録する
+}
+
+// Result is the outcome of a test
+type Result struct {
+	Name     string
+	Passed   bool
+	Message  string // 失敗の内容
+	Duration time.Duration
+}
+
+type testCase struct {
+	name string
+	fn   object.Object
+}
+
+// RunFile runs the tests registered in src read from path.
+// an error is returned when the file itself couldn't be parsed or evaluated.
+func RunFile(path, src string, opts Options) ([]Result, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s", strings.Join(p.Errors(), "\n"))
+	}
+
+	// 最初の評価で登録されたtestを集める. 出力とcoverageはこの評価だけのもの
+	if opts.Coverage != nil {
+		opts.Coverage.Add(path, program)
+		evaluator.SetHook(opts.Coverage)
+	}
+	cases, err := load(program)
+	evaluator.SetHook(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Result{}
+	for i, tc := range cases {
+		if opts.Run != nil && !opts.Run.MatchString(tc.name) {
+			continue
+		}
+		results = append(results, runTest(program, i, tc.name, opts))
+	}
+	return results, nil
+}
+
+// programを新しいenvironmentで評価して登録されたtestを集める
+func load(program *ast.Program) ([]testCase, error) {
+	cases := []testCase{}
+	evaluator.SetTestCollector(func(name string, fn object.Object) {
+		cases = append(cases, testCase{name: name, fn: fn})
+	})
+	defer evaluator.SetTestCollector(nil)
+
+	env := object.NewEnvironment()
+	env.Set("args", &object.Array{Elements: []object.Object{}})
+	if errObj, ok := evaluator.Eval(program, env).(*object.Error); ok {
+		return nil, fmt.Errorf("%s", errObj.Inspect())
+	}
+	return cases, nil
+}
+
+// testごとにprogramを評価し直し, 他のtestが変えた値の影響を受けない環境で呼ぶ.
+// 評価し直す間の出力は捨て, coverageにも数えない
+func runTest(program *ast.Program, index int, name string, opts Options) Result {
+	result := Result{Name: name}
+
+	out := evaluator.Output()
+	evaluator.SetOutput(ioutil.Discard)
+	cases, err := load(program)
+	evaluator.SetOutput(out)
+	if err == nil && (index >= len(cases) || cases[index].name != name) {
+		err = fmt.Errorf("test %s is not registered when the file is evaluated again", name)
+	}
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	if opts.Coverage != nil {
+		evaluator.SetHook(opts.Coverage)
+		defer evaluator.SetHook(nil)
+	}
+	start := time.Now()
+	evaluated := evaluator.Call(cases[index].fn)
+	result.Duration = time.Since(start)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		result.Message = errObj.Message
+		return result
+	}
+	result.Passed = true
+	return result
+}
+
+// Report prints results of a file in the style of `go test` and reports whether all tests passed
+func Report(out io.Writer, path string, results []Result, err error, opts Options) bool {
+	if err != nil {
+		fmt.Fprintf(out, "FAIL\t%s\n%s\n", path, indent(err.Error()))
+		return false
+	}
+
+	failed := 0
+	for _, r := range results {
+		if opts.Verbose {
+			fmt.Fprintf(out, "=== RUN   %s\n", r.Name)
+		}
+		if r.Passed {
+			if opts.Verbose {
+				fmt.Fprintf(out, "--- PASS: %s (%.2fs)\n", r.Name, r.Duration.Seconds())
+			}
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "--- FAIL: %s (%.2fs)\n%s\n", r.Name, r.Duration.Seconds(), indent(r.Message))
+	}
+
+	switch {
+	case len(results) == 0:
+		fmt.Fprintf(out, "ok  \t%s\t[no tests to run]\n", path)
+	case failed == 0:
+		fmt.Fprintf(out, "ok  \t%s\t%d passed\n", path, len(results))
+	default:
+		fmt.Fprintf(out, "FAIL\t%s\t%d failed, %d passed\n", path, failed, len(results)-failed)
+	}
+	return failed == 0
+}
+
+func indent(text string) string {
+	return "    " + strings.ReplaceAll(text, "\n", "\n    ")
+}
@@ -0,0 +1,174 @@
+package tester
+
+import (
+	"bytes"
+	"choco/src/evaluator"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const source = `let counter = [0]
+let double = fn(x) { x * 2 }
+
+test("double", fn() {
+    assertEq(double(2), 4)
+})
+
+test("failing", fn() {
+    assertEq(double(2), 5, "double")
+    puts("not reached")
+})
+
+test("isolated", fn() {
+    let counter = push(counter, 1)
+    assertEq(len(counter), 2)
+})
+
+test("throws", fn() {
+    assertThrows(fn() { 1 + true }, "type mismatch")
+})
+`
+
+func TestRunFile(t *testing.T) {
+	results, err := RunFile("a_test.choco", source, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		name    string
+		passed  bool
+		message string
+	}{
+		{"double", true, ""},
+		{"failing", false, "assertion failed: double\nexpected: 5\n  actual: 4\n          ^"},
+		{"isolated", true, ""},
+		{"throws", true, ""},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("wrong number of results. got=%+v", results)
+	}
+	for i, r := range results {
+		want := expected[i]
+		if r.Name != want.name || r.Passed != want.passed || r.Message != want.message {
+			t.Errorf("results[%d]: want=%+v, got=%+v", i, want, r)
+		}
+	}
+}
+
+func TestRunFileFilter(t *testing.T) {
+	results, err := RunFile("a_test.choco", source, Options{Run: regexp.MustCompile("^d")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "double" {
+		t.Errorf("wrong results. got=%+v", results)
+	}
+}
+
+func TestRunFileEvaluatesOnce(t *testing.T) {
+	var out bytes.Buffer
+	evaluator.SetOutput(&out)
+	defer evaluator.SetOutput(os.Stdout)
+
+	input := "puts(\"loaded\")\ntest(\"a\", fn() { 1 })\ntest(\"b\", fn() { 1 })\ntest(\"c\", fn() { 1 })"
+	results, err := RunFile("a_test.choco", input, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("wrong number of results. got=%+v", results)
+	}
+	if out.String() != "loaded\n" {
+		t.Errorf("top-level statements should run once. got=%q", out.String())
+	}
+}
+
+func TestRunFileIsolation(t *testing.T) {
+	input := `let counter = fn() { yield 1; yield 2 }
+let it = counter()
+test("a", fn() { assertEq(next(it), 1) })
+test("b", fn() { assertEq(next(it), 1) })
+`
+	// 他のtestが進めたgeneratorは次のtestに残らない
+	for _, run := range []string{"", "^a$", "^b$", "^b|a$"} {
+		opts := Options{}
+		if run != "" {
+			opts.Run = regexp.MustCompile(run)
+		}
+		results, err := RunFile("a_test.choco", input, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if !r.Passed {
+				t.Errorf("-run %q: %s failed: %s", run, r.Name, r.Message)
+			}
+		}
+	}
+}
+
+func TestRunFileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 1", "expected next token is"},
+		{"test(\"x\", fn() { 1 })\nlen(1)", "argument to `len` not supported"},
+	}
+	for _, tt := range tests {
+		_, err := RunFile("a_test.choco", tt.input, Options{})
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%q: wrong error. got=%v", tt.input, err)
+		}
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{`assert(true)`, ""},
+		{`assert(1 > 2)`, "assertion failed"},
+		{`assert(false, "message")`, "assertion failed: message"},
+		{`assertEq([1, 2], [1, 2])`, ""},
+		{`assertEq({"a": [1], "b": 2}, {"b": 2, "a": [1]})`, ""},
+		{`assertEq(1, "1")`, "assertion failed\nexpected: 1 (STRING)\n  actual: 1 (INTEGER)\n             ^"},
+		{"assertEq(\"a\nb\nc\", \"a\nc\")", "assertion failed\n--- expected\n+++ actual\n  a\n+ b\n  c"},
+		{`assertThrows(fn() { 1 })`, "assertion failed: expected an error, got 1"},
+		{`assertThrows(fn() { -true }, "type")`, "assertion failed: expected an error containing \"type\", got \"unknown operator: -BOOLEAN\""},
+		{`assertThrows(fn() { assert(false) })`, ""},
+		{`assertThrows(42)`, "first argument to `assertThrows` must be FUNCTION, got INTEGER"},
+		{`assertThrows(len)`, ""},
+	}
+	for _, tt := range tests {
+		results, err := RunFile("a_test.choco", `test("t", fn() { `+tt.body+` })`, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[0].Message != tt.expected || results[0].Passed != (tt.expected == "") {
+			t.Errorf("%s: want=%q, got=%q", tt.body, tt.expected, results[0].Message)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	results, _ := RunFile("a_test.choco", source, Options{})
+	var out bytes.Buffer
+	if Report(&out, "a_test.choco", results, nil, Options{}) {
+		t.Errorf("report should fail")
+	}
+	got := regexp.MustCompile(`\d+\.\d+s`).ReplaceAllString(out.String(), "0.00s")
+	expected := "--- FAIL: failing (0.00s)\n" +
+		"    assertion failed: double\n" +
+		"    expected: 5\n" +
+		"      actual: 4\n" +
+		"              ^\n" +
+		"FAIL\ta_test.choco\t1 failed, 3 passed\n"
+	if got != expected {
+		t.Errorf("wrong report.\nwant=%q\ngot =%q", expected, got)
+	}
+}
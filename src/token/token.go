@@ -1,28 +1,42 @@
 package token
 
+import "sort"
+
 type TokenType string
 
 // token = 字面 + type + 意味値
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1始まり
+	Column  int // 1始まり. byte単位
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
+	COMMENT = "COMMENT" // parserには渡さない
+
 	IDENT  = "IDENT"
 	INT    = "INT"
 	STRING = "STRING"
 
 	ASSIGN   = "="
+	ARROW    = "=>" // matchの腕
 	PLUS     = "+"
 	MINUS    = "-"
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
 
+	PIPE      = "|"
+	AMPERSAND = "&"
+
+	DOTDOT   = ".." // 終端を含むrange
+	DOTDOTLT = "..<"
+	ELLIPSIS = "..." // 可変長引数とspread
+
 	LT = "<"
 	GT = ">"
 
@@ -44,6 +58,8 @@ const (
 	LBRACKET = "["
 	RBRACKET = "]"
 
+	SET_LBRACE = "#{" // set literalの開始
+
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
 	TRUE     = "TRUE"
@@ -51,6 +67,10 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	FOR      = "FOR"
+	IN       = "IN"
+	YIELD    = "YIELD"
+	MATCH    = "MATCH"
 )
 
 var keywords = map[string]TokenType{
@@ -61,6 +81,20 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"for":    FOR,
+	"in":     IN,
+	"yield":  YIELD,
+	"match":  MATCH,
+}
+
+// Keywords returns the sorted reserved words
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func LookupIdent(ident string) TokenType {
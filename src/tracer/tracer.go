@@ -0,0 +1,95 @@
+// Package tracer prints how a choco program is evaluated
+package tracer
+
+import (
+	"choco/src/ast"
+	"choco/src/object"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MAX_WIDTH is the maximum number of characters of a statement or value in a trace
+const MAX_WIDTH = 60
+
+const indentPlaceholder = "  "
+
+// Tracer is an evaluator.Hook which prints statements, calls with their arguments,
+// return values and errors, indented by the depth of calls
+type Tracer struct {
+	out   io.Writer
+	depth int
+}
+
+// New returns a tracer which writes to out
+func New(out io.Writer) *Tracer {
+	return &Tracer{out: out}
+}
+
+// Statement implements evaluator.Hook
+func (t *Tracer) Statement(stmt ast.Statement, env *object.Environment) {
+	pos := ast.Start(stmt)
+	t.printf("%d:%d %s", pos.Line, pos.Column, shorten(stmt.String()))
+}
+
+// Branch implements evaluator.Hook
+func (t *Tracer) Branch(node *ast.IfExpression, consequence bool) {}
+
+// Call implements evaluator.Hook
+func (t *Tracer) Call(call *ast.CallExpression, fn object.Object, args []object.Object, env *object.Environment) {
+	values := make([]string, 0, len(args))
+	for _, arg := range args {
+		values = append(values, shorten(arg.Inspect()))
+	}
+	t.printf("-> %s(%s)", name(call, fn), strings.Join(values, ", "))
+	t.depth++
+}
+
+// Return implements evaluator.Hook
+func (t *Tracer) Return(call *ast.CallExpression, fn object.Object, result object.Object) {
+	if t.depth > 0 {
+		t.depth--
+	}
+	value := "null"
+	if result != nil {
+		value = shorten(result.Inspect())
+	}
+	t.printf("<- %s = %s", name(call, fn), value)
+}
+
+// Error implements evaluator.Hook
+func (t *Tracer) Error(err *object.Error) {
+	t.printf("!! %s", shorten(err.Inspect()))
+}
+
+func (t *Tracer) printf(format string, a ...interface{}) {
+	fmt.Fprintf(t.out, "%s%s\n", strings.Repeat(indentPlaceholder, t.depth), fmt.Sprintf(format, a...))
+}
+
+// 束縛された名前があればそれを, なければ呼び出し側の式を使う
+func name(call *ast.CallExpression, fn object.Object) string {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if fn.Name != "" {
+			return fn.Name
+		}
+	case *object.Builtin:
+		if fn.Name != "" {
+			return fn.Name
+		}
+	}
+	if call != nil {
+		return shorten(call.Function.String())
+	}
+	return "fn"
+}
+
+// 改行をエスケープし, 長すぎる場合は末尾を省略する
+func shorten(s string) string {
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	runes := []rune(s)
+	if len(runes) > MAX_WIDTH {
+		return string(runes[:MAX_WIDTH-3]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package tracer
+
+import (
+	"bytes"
+	"choco/src/evaluator"
+	"choco/src/lexer"
+	"choco/src/object"
+	"choco/src/parser"
+	"strings"
+	"testing"
+)
+
+func trace(input string) string {
+	var out bytes.Buffer
+	evaluator.SetHook(New(&out))
+	defer evaluator.SetHook(nil)
+	program := parser.New(lexer.New(input)).ParseProgram()
+	evaluator.Eval(program, object.NewEnvironment())
+	return out.String()
+}
+
+func TestTrace(t *testing.T) {
+	input := `let add = fn(a, b) { a + b }
+let twice = fn(f, x) {
+    f(f(x, 1), 1)
+}
+twice(add, "a")
+`
+	expected := `1:1 let add = fn(a,b)(a+b);
+2:1 let twice = fn(f,x)f(f(x,1),1);
+5:1 twice(add,a)
+-> twice(fn(a, b) {\n(a+b)\n, a)
+  3:5 f(f(x,1),1)
+  -> add(a, 1)
+    1:22 (a+b)
+    !! ERROR: type mismatch: STRING + INTEGER
+  <- add = ERROR: type mismatch: STRING + INTEGER
+<- twice = ERROR: type mismatch: STRING + INTEGER
+`
+	if got := trace(input); got != expected {
+		t.Errorf("wrong trace.\nwant=%q\ngot =%q", expected, got)
+	}
+}
+
+func TestTraceShortensValues(t *testing.T) {
+	got := trace(`len("` + strings.Repeat("x", 100) + `")`)
+	expected := "-> len(" + strings.Repeat("x", MAX_WIDTH-3) + "...)\n"
+	if !strings.Contains(got, expected) {
+		t.Errorf("long value is not shortened. got=%q", got)
+	}
+}
+
+func TestTraceAnonymousFunction(t *testing.T) {
+	got := trace("fn(x) { x }(1)")
+	if !strings.Contains(got, "-> fn(x)x(1)\n") {
+		t.Errorf("anonymous function is not named by the callee. got=%q", got)
+	}
+}